@@ -8,7 +8,7 @@ class Blisp < Formula
   depends_on "go" => :build
 
   def install
-    system "go", "build", "-o", "#{bin}/blisp"
+    system "go", "build", "-o", "#{bin}/blisp", "./cmd/blisp"
     chmod 0755, "#{bin}/blisp"
   end
 end
\ No newline at end of file
@@ -1,347 +1,406 @@
-package main
+package blisp
 
 import (
 	"fmt"
-	"log"
-	"os"
 )
 
-func validateRangeParam(name string, rangeToCheck [2]int, numOfParams int) {
-	if numOfParams < rangeToCheck[0] || numOfParams > rangeToCheck[1] {
-		log.Fatal("Invalid number of parameters to \"", name, "\", expected range from ", rangeToCheck[0], " to ", rangeToCheck[1], " found ", numOfParams)
-	}
-}
-
-func validateNumParam(name string, numToCheck int, numOfParams int) {
-	if numToCheck != numOfParams {
-		log.Fatal("Invalid number of parameters to \"", name, "\", expected ", numToCheck, " found ", numOfParams)
-	}
-}
-
 func InitBuiltins(ds *dataStore) {
-	ds.builtins = []builtin{
+	RegisterBuiltins(ds, []builtin{
 		{
-			name: "print",
+			name:  "print",
+			usage: "values...",
+			doc:   "prints the values separated by commas",
+			min:   0, max: -1,
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
 				Print(ds, params...)
 				return nil
 			},
 		},
 		{
-			name: "+",
+			name:  "+",
+			usage: "values...",
+			doc:   "sum of numbers, or the strings joined together",
+			min:   0, max: -1,
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
 				return &[]dataType{Add(ds, params...)}
 			},
 		},
 		{
-			name: "-",
+			name:  "-",
+			usage: "values...",
+			doc:   "first number minus the rest",
+			min:   0, max: -1,
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
 				return &[]dataType{Sub(ds, params...)}
 			},
 		},
 		{
-			name: "*",
+			name:  "*",
+			usage: "values...",
+			doc:   "product of the numbers",
+			min:   0, max: -1,
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
 				return &[]dataType{Mult(ds, params...)}
 			},
 		},
 		{
-			name: "/",
+			name:  "/",
+			usage: "values...",
+			doc:   "first number divided by the rest",
+			min:   0, max: -1,
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
 				return &[]dataType{Divide(ds, params...)}
 			},
 		},
 		{
-			name: "^",
+			name:  "^",
+			usage: "base exp",
+			doc:   "base raised to the power exp",
+			min:   2, max: 2,
+			types: [][]DataType{numberTypes, numberTypes},
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) != 2 {
-					log.Fatal("Invalid number of parameters to \"^\". Expected 2 found ", len(params))
-				}
 				return &[]dataType{Exp(ds, params[0], params[1])}
 			},
 		},
 		{
-			name: "%",
+			name:  "%",
+			usage: "a b",
+			doc:   "remainder of a divided by b",
+			min:   2, max: 2,
+			types: [][]DataType{intTypes, intTypes},
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) != 2 {
-					log.Fatal("Invalid number of parameters to \"%\". Expected 2 found ", len(params))
-				}
 				return &[]dataType{Mod(ds, params[0], params[1])}
 			},
 		},
 		{
-			name: "eval",
+			name:  "exact-mode",
+			usage: "[on]",
+			doc:   "true if dividing Ints gives Rationals, setting it to on if given",
+			min:   0, max: 1,
+			types: [][]DataType{{Bool}},
+			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
+				if len(params) > 0 {
+					ds.exact = GetDsValue(ds, params[0]).value.(bool)
+				}
+				return &[]dataType{{dataType: Bool, value: ds.exact}}
+			},
+		},
+		{
+			name:  "eval",
+			usage: "code...",
+			doc:   "evaluates code given as strings, or as a list or symbol made by quote",
+			min:   1, max: -1,
+			types: [][]DataType{{String, List, Symbol}},
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				strVal := params[0].value.(string)
+				if code := GetDsValue(ds, params[0]); code.dataType == List || code.dataType == Symbol {
+					return eval(ds, []*node{dataToNode(code, position{})}, scopes)
+				}
+				strVal := GetDsValue(ds, params[0]).value.(string)
 				if len(params) == 1 {
 					toEval := PrepQuotesString(strVal)
-					return Eval(ds, Tokenize(toEval), scopes)
+					return eval(ds, ParseTokens(Tokenize("eval", toEval)), scopes)
 				} else {
 					for _, v := range params {
 						strVal := v.value.(string)
-						Eval(ds, Tokenize(strVal), scopes)
+						eval(ds, ParseTokens(Tokenize("eval", strVal)), scopes)
 					}
 					return nil
 				}
 			},
 		},
 		{
-			name: "var",
+			name:  "var",
+			usage: "name value",
+			doc:   "defines a variable in the current scope, name can be a pattern like [a b] or {a b}",
+			min:   2, max: 2,
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) != 2 {
-					log.Fatal("Invalid number of parameters to \"var\". Expected 2 found ", len(params))
-				}
-				MakeVar(ds, scopes, params[0].value.(string), params[1], false)
+				MakeVars(ds, scopes, params[0], params[1], false)
 				return nil
 			},
 		},
 		{
-			name: "const",
+			name:  "const",
+			usage: "name value",
+			doc:   "defines a variable that can not be set again",
+			min:   2, max: 2,
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				MakeVar(ds, scopes, params[0].value.(string), params[1], true)
+				MakeVars(ds, scopes, params[0], params[1], true)
 				return nil
 			},
 		},
 		{
-			name: "set",
+			name:  "set",
+			usage: "name [key] value",
+			doc:   "sets a variable, or the item at key in the list or struct it holds",
+			min:   2, max: 3,
+			types: [][]DataType{nameTypes},
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if _, ok := ds.vars[params[0].value.(string)]; !ok {
-					log.Fatal("Cannot set variable: ", params[0].value, ", variable is not initialized")
+				if CurrentEnv(ds).lookupVar(params[0].value.(string)) == nil {
+					throwError(NameError, "Cannot set variable: ", params[0].value, ", variable is not initialized")
 				}
 				if len(params) == 2 {
 					SetVar(ds, params[0].value.(string), params[1])
-				} else if len(params) == 3 {
-					SetValue(ds, params[0], params[1], params[2])
 				} else {
-					log.Fatal("Error in \"set\", invalid number of parameters: ", len(params))
+					SetValue(ds, params[0], params[1], params[2])
 				}
 				return nil
 			},
 		},
 		{
-			name: "free",
+			name:  "free",
+			usage: "name",
+			doc:   "removes a variable",
+			min:   1, max: 1,
+			types: [][]DataType{nameTypes},
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) != 1 {
-					log.Fatal("Invalid number of parameters to \"free\". Expected 1 found ", len(params))
-				}
 				FreeVar(ds, params[0].value.(string))
 				return nil
 			},
 		},
 		{
-			name: "type",
+			name:  "type",
+			usage: "value",
+			doc:   "name of the type of value",
+			min:   1, max: 1,
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) != 1 {
-					log.Fatal("Invalid number of parameters to \"type\". Expected 1 found ", len(params))
-				}
 				return &[]dataType{{dataType: String, value: GetType(ds, params[0])}}
 			},
 		},
 		{
-			name: "get",
+			name:  "get",
+			usage: "value key",
+			doc:   "item at key in a list, string or struct, or the field named key of a variant",
+			min:   2, max: 2,
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) != 2 {
-					log.Fatal("Invalid number of parameters to \"get\". Expected 2 found ", len(params))
-				}
 				return &[]dataType{GetFromValue(ds, params[0], params[1])}
 			},
 		},
 		{
-			name: "loop",
+			name:  "loop",
+			usage: "items [index] item body",
+			doc:   "runs body for each item of a list or map, or each int up to a number",
+			min:   3, max: 4,
+			types: [][]DataType{{List, Map, Int}},
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) == 3 {
-					val := params[0]
-					if val.dataType == Ident {
-						val = GetDsValue(ds, val)
-					}
-					if val.dataType == List {
-						res := LoopListIterator(ds, scopes, val, params[1], params[2])
-						ds.inLoop = false
-						return res
-					} else if val.dataType == Int {
-						res := LoopTo(ds, scopes, val, params[1], params[2])
-						ds.inLoop = false
-						return res
-					} else {
-						log.Fatal("Error in \"Loop\". Expected first param to be \"List\" or \"Int\", found ", dataTypes[val.dataType])
-					}
-				} else if len(params) == 4 {
+				if len(params) == 4 {
 					ds.inLoop = true
-					val := params[0]
-					if val.dataType == Ident {
-						val = GetDsValue(ds, val)
-					}
-					if val.dataType == List {
-						res := LoopListIndexIterator(ds, scopes, val, params[1], params[2], params[3])
-						ds.inLoop = false
-						return res
-					} else if val.dataType == Int {
-						res := LoopFromTo(ds, scopes, val, params[1], params[2], params[3])
-						ds.inLoop = false
-						return res
-					} else {
-						log.Fatal("Error in \"Loop\". Expected first param to be list, got: ", dataTypes[val.dataType])
-					}
 				}
+				val := GetDsValue(ds, params[0])
+				var res *[]dataType
+				switch {
+				case val.dataType == List && len(params) == 3:
+					res = LoopListIterator(ds, scopes, val, params[1], params[2])
+				case val.dataType == List:
+					res = LoopListIndexIterator(ds, scopes, val, params[1], params[2], params[3])
+				case val.dataType == Int && len(params) == 3:
+					res = LoopTo(ds, scopes, val, params[1], params[2])
+				case val.dataType == Int:
+					res = LoopFromTo(ds, scopes, val, params[1], params[2], params[3])
+				case len(params) == 3:
+					res = LoopMapIterator(ds, scopes, val.value.(*hashMap), params[1], nil, params[2])
+				default:
+					res = LoopMapIterator(ds, scopes, val.value.(*hashMap), params[1], &params[2], params[3])
+				}
+				ds.inLoop = false
+				return res
+			},
+		},
+		{
+			name:  "scan-line",
+			usage: "[name]",
+			doc:   "reads a line from stdin, into the variable name if given",
+			min:   0, max: 1,
+			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
+				line := ""
+				fmt.Scanln(&line)
+				if len(params) == 0 {
+					return &[]dataType{{dataType: String, value: line}}
+				}
+				if params[0].dataType != Ident {
+					throwError(RuntimeError, "Unable to assign value to", params[0])
+				}
+				SetVar(ds, params[0].value.(string), dataType{dataType: String, value: line})
 				return nil
 			},
 		},
 		{
-			name: "scan-line",
+			name:  "if",
+			usage: "condition then [else]",
+			doc:   "value of then if condition is true, otherwise of else",
+			min:   2, max: 3,
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) == 0 {
-					line := ""
-					fmt.Scanln(&line)
-					return &[]dataType{{dataType: String, value: line}}
-				} else if len(params) == 1 {
-					line := ""
-					fmt.Scanln(&line)
-					if params[0].dataType == Ident {
-						SetVar(ds, params[0].value.(string), dataType{dataType: String, value: line})
-						return nil
-					} else {
-						log.Fatal("Unable to assign value to", params[0])
-					}
-				} else {
-					log.Fatal("Invalid number of parameters to \"scan-line\". Expected 0 or 2 found ", len(params))
+				return If(ds, scopes, params...)
+			},
+		},
+		{
+			name:  "cond",
+			usage: "(test exprs...)... [(else exprs...)]",
+			doc:   "runs the exprs of the first clause whose test is true, returning the value of the last one",
+			min:   0, max: -1,
+			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
+				return Cond(ds, scopes, params)
+			},
+		},
+		{
+			name:  "when",
+			usage: "test exprs...",
+			doc:   "runs the exprs if test is true, returning the value of the last one",
+			min:   1, max: 2,
+			types: [][]DataType{{Bool}},
+			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
+				if len(params) == 1 {
+					return nil
 				}
-				return nil
+				return When(ds, scopes, "when", params)
 			},
 		},
 		{
-			name: "if",
+			name:  "unless",
+			usage: "test exprs...",
+			doc:   "runs the exprs if test is false, returning the value of the last one",
+			min:   1, max: 2,
+			types: [][]DataType{{Bool}},
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) == 2 || len(params) == 3 {
-					val := If(ds, scopes, params...)
-					return val
-				} else {
-					log.Fatal("Invalid number of parameters to \"if\". Expected 2 found ", len(params))
+				if len(params) == 1 {
+					return nil
 				}
-				return nil
+				return When(ds, scopes, "unless", params)
 			},
 		},
 		{
-			name: "body",
+			name:  "match",
+			usage: "value (pattern [when guard] result)...",
+			doc:   "value of the result of the first arm whose pattern matches value",
+			min:   1, max: -1,
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				return Eval(ds, params[0].value.([]token), scopes)
+				return Match(ds, scopes, params)
 			},
 		},
 		{
-			name: "eq",
+			name:  "eq",
+			usage: "values...",
+			doc:   "true if every value is equal",
+			min:   1, max: -1,
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) > 0 {
-					return &[]dataType{{dataType: Bool, value: Eq(ds, params...)}}
-				} else {
-					log.Fatal("Invalid number of parameters to \"eq\". Expected 1 or more found ", len(params))
-				}
-				return nil
+				return &[]dataType{{dataType: Bool, value: Eq(ds, params...)}}
 			},
 		},
 		{
-			name: "append",
+			name:  "append",
+			usage: "list items...",
+			doc:   "list with the items added to the end, a variable holding the list is changed too",
+			min:   2, max: -1,
+			types: [][]DataType{listTypes},
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) < 2 {
-					log.Fatal("Invalid number of parameters to \"append\". Expected 2 or more found ", len(params))
-				}
-				res := ListFunc(ds, AppendToList, params...)
-				return &[]dataType{res}
+				return &[]dataType{ListFunc(ds, AppendToList, params...)}
 			},
 		},
 		{
-			name: "prepend",
+			name:  "prepend",
+			usage: "list items...",
+			doc:   "list with the items added to the start, a variable holding the list is changed too",
+			min:   2, max: -1,
+			types: [][]DataType{listTypes},
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) < 2 {
-					log.Fatal("Invalid number of parameters to \"prepend\". Expected 2 or more found ", len(params))
-				}
-				res := ListFunc(ds, PrependToList, params...)
-				return &[]dataType{res}
+				return &[]dataType{ListFunc(ds, PrependToList, params...)}
 			},
 		},
 		{
-			name: "concat",
+			name:  "concat",
+			usage: "string values...",
+			doc:   "the string with the values appended",
+			min:   0, max: -1,
+			types: [][]DataType{stringTypes},
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
 				return &[]dataType{{dataType: String, value: Concat(ds, params...)}}
 			},
 		},
 		{
-			name: "exit",
+			name:  "exit",
+			usage: "[code]",
+			doc:   "ends the program with the exit code, 0 if not given",
+			min:   0, max: 1,
+			types: [][]DataType{intTypes},
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) == 0 {
-					os.Exit(0)
-				} else if len(params) == 1 {
-					os.Exit(params[0].value.(int))
-				} else {
-					log.Fatal("Invalid number of parameters to \"exit\". Expected 0, 1, or more found ", len(params))
+				code := 0
+				if len(params) > 0 {
+					code = intOf("exit", GetDsValue(ds, params[0]))
 				}
-				return nil
+				panic(&ExitError{Code: code})
 			},
 		},
 		{
 			name: "break",
+			doc:  "ends the loop it is in",
+			min:  0, max: 0,
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
 				return &[]dataType{{dataType: BreakVal, value: nil}}
 			},
 		},
 		{
-			name: "pop",
+			name:  "pop",
+			usage: "list",
+			doc:   "removes and returns the last item of the list in a variable",
+			min:   1, max: 1,
+			types: [][]DataType{listTypes},
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) != 1 {
-					log.Fatal("Invalid number of parameters to \"pop\". Expected 1 found ", len(params))
-				}
 				return &[]dataType{Pop(ds, params[0])}
 			},
 		},
 		{
-			name: "remove",
+			name:  "remove",
+			usage: "value key",
+			doc:   "removes and returns the item at key of the list or struct in a variable",
+			min:   2, max: 2,
+			types: [][]DataType{{List, Struct}},
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) != 2 {
-					log.Fatal("Invalid number of parameters to \"remove\". Expected 2 found ", len(params))
-				}
 				return &[]dataType{Remove(ds, params[0], params[1])}
 			},
 		},
 		{
-			name: "len",
+			name:  "len",
+			usage: "value",
+			doc:   "number of items in a list, string or map",
+			min:   1, max: 1,
+			types: [][]DataType{{List, String, Map}},
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) != 1 {
-					log.Fatal("Invalid number of parameters to \"len\". Expected 1 found ", len(params))
-				}
 				return &[]dataType{{dataType: Int, value: Len(ds, params[0])}}
 			},
 		},
 		{
-			name: "and",
+			name:  "and",
+			usage: "values...",
+			doc:   "true if every value is true",
+			min:   1, max: -1,
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) == 0 {
-					log.Fatal("Invalid number of parameters to \"and\". Expected 1 or more found ", len(params))
-				}
 				return &[]dataType{{dataType: Bool, value: And(ds, params...)}}
 			},
 		},
 		{
-			name: "or",
+			name:  "or",
+			usage: "values...",
+			doc:   "true if any value is true",
+			min:   1, max: -1,
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) == 0 {
-					log.Fatal("Invalid number of parameters to \"or\". Expected 1 or more found ", len(params))
-				}
 				return &[]dataType{{dataType: Bool, value: Or(ds, params...)}}
 			},
 		},
 		{
-			name: "not",
+			name:  "not",
+			usage: "value",
+			doc:   "true if value is false",
+			min:   1, max: 1,
+			types: [][]DataType{{Bool}},
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) != 1 {
-					log.Fatal("Invalid number of parameters to \"not\". Expected 1 or more found ", len(params))
-				}
 				return &[]dataType{{dataType: Bool, value: Not(ds, params[0])}}
 			},
 		},
 		{
-			name: "func",
+			name:  "func",
+			usage: "name params... body",
+			doc:   "defines a function, the name _ makes one without a name and returns it",
+			min:   2, max: -1,
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) < 2 {
-					log.Fatal("Invalid number of parameters to \"func\". Expected 3 or more found ", len(params))
-				}
 				f := MakeFunction(ds, scopes, params[0], params[1:])
 				// f can be nil, but only when returned is false
 				if f == nil {
@@ -351,282 +410,500 @@ func InitBuiltins(ds *dataStore) {
 			},
 		},
 		{
-			name: "return",
+			name:  "return",
+			usage: "value",
+			doc:   "ends the function it is in with value",
+			min:   1, max: 1,
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
 				if !ds.inFunc {
-					log.Fatal("Not in func, cannot return")
-				}
-				if len(params) != 1 {
-					log.Fatal("Invalid number of parameters to \"return\". Expected 1 found ", len(params))
+					throwError(RuntimeError, "Not in func, cannot return")
 				}
 				val := GetDsValue(ds, params[0])
 				return &[]dataType{{dataType: ReturnVal, value: val}}
 			},
 		},
 		{
-			name: "parse",
+			name:  "parse",
+			usage: "string",
+			doc:   "number the string holds",
+			min:   1, max: 1,
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				var res dataType
-				if len(params) == 1 {
-					res = Parse(ds, params[0])
-				} else {
-					log.Fatal("Invalid number of parameters to \"parse\". Expected 1 found ", len(params))
-				}
-				return &[]dataType{res}
+				return &[]dataType{Parse(ds, params[0])}
 			},
 		},
 		{
-			name: "<",
+			name:  "<",
+			usage: "a b",
+			doc:   "true if a is less than b",
+			min:   2, max: 2,
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) != 2 {
-					log.Fatal("Invalid number of parameters to \"<\". Expected 2 found ", len(params))
-				}
 				return &[]dataType{{dataType: Bool, value: LessThan(ds, params[0], params[1])}}
 			},
 		},
 		{
-			name: "<=",
+			name:  "<=",
+			usage: "a b",
+			doc:   "true if a is less than or equal to b",
+			min:   2, max: 2,
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) != 2 {
-					log.Fatal("Invalid number of parameters to \"<=\". Expected 2 found ", len(params))
-				}
 				return &[]dataType{{dataType: Bool, value: LessThanOrEqualTo(ds, params[0], params[1])}}
 			},
 		},
 		{
-			name: ">",
+			name:  ">",
+			usage: "a b",
+			doc:   "true if a is greater than b",
+			min:   2, max: 2,
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) != 2 {
-					log.Fatal("Invalid number of parameters to \">\". Expected 2 found ", len(params))
-				}
 				return &[]dataType{{dataType: Bool, value: LessThan(ds, params[1], params[0])}}
 			},
 		},
 		{
-			name: ">=",
+			name:  ">=",
+			usage: "a b",
+			doc:   "true if a is greater than or equal to b",
+			min:   2, max: 2,
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) != 2 {
-					log.Fatal("Invalid number of parameters to \">=\". Expected 2 found ", len(params))
-				}
 				return &[]dataType{{dataType: Bool, value: LessThanOrEqualTo(ds, params[1], params[0])}}
 			},
 		},
 		{
-			name: "read",
+			name:  "read",
+			usage: "path",
+			doc:   "contents of the file at path",
+			min:   1, max: 1,
+			types: [][]DataType{stringTypes},
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) != 1 {
-					log.Fatal("Invalid number of parameters to \"read\". Expected 1 found ", len(params))
-				}
 				return &[]dataType{{dataType: String, value: GetFile(ds, params[0])}}
 			},
 		},
 		{
-			name: "write",
+			name:  "write",
+			usage: "path string",
+			doc:   "writes the string to the file at path",
+			min:   2, max: 2,
+			types: [][]DataType{stringTypes, stringTypes},
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) != 2 {
-					log.Fatal("Invalid number of parameters to \"write\". Expected 2 found ", len(params))
-				}
 				WriteFile(ds, params[0], params[1])
 				return nil
 			},
 		},
 		{
-			name: "substr",
+			name:  "substr",
+			usage: "string start [end]",
+			doc:   "part of the string from start up to end, or to the end of the string",
+			min:   2, max: 3,
+			types: [][]DataType{stringTypes, intTypes, intTypes},
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
 				if len(params) == 2 {
 					return &[]dataType{{dataType: String, value: SubstrEnd(ds, params[0], params[1])}}
-				} else if len(params) == 3 {
-					return &[]dataType{{dataType: String, value: Substr(ds, params[0], params[1], params[2])}}
 				}
-				return nil
+				return &[]dataType{{dataType: String, value: Substr(ds, params[0], params[1], params[2])}}
 			},
 		},
 		{
-			name: "struct",
+			name:  "struct",
+			usage: "name value...",
+			doc:   "struct with the attributes given as pairs of names and values",
+			min:   0, max: -1,
+			rest: [][]DataType{{Ident, String}, nil},
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
 				return &[]dataType{MakeStruct(ds, params...)}
 			},
 		},
 		{
-			name: "shift",
+			name:  "extend",
+			usage: "proto name value...",
+			doc:   "struct like (struct name value...) that looks up attributes it does not have in proto",
+			min:   1, max: -1,
+			types: [][]DataType{{Struct}},
+			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
+				return &[]dataType{Extend(ds, params)}
+			},
+		},
+		{
+			name:  "proto",
+			usage: "struct",
+			doc:   "prototype of the struct, or nil if it has none",
+			min:   1, max: 1,
+			types: [][]DataType{{Struct}},
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) != 1 {
-					log.Fatal("Invalid number of parameters to \"shift\". Expected 1 found ", len(params))
+				return &[]dataType{Proto(ds, params[0])}
+			},
+		},
+		{
+			name:  "super",
+			usage: "method args...",
+			doc:   "calls method on this, looking it up after the struct the running method was found on",
+			min:   1, max: -1,
+			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
+				if params[0].dataType != Ident {
+					throwError(TypeError, "Error in \"super\", expected \"Ident\" found ", dataTypes[params[0].dataType])
 				}
+				return Super(ds, scopes, params)
+			},
+		},
+		{
+			name:  "protocol",
+			usage: "name (method params...)...",
+			doc:   "declares a protocol, the methods a struct needs and the args they take",
+			min:   1, max: -1,
+			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
+				DefProtocol(ds, scopes, params)
+				return nil
+			},
+		},
+		{
+			name:  "implements",
+			usage: "struct protocol...",
+			doc:   "checks the struct has the methods of each protocol and returns it claiming them",
+			min:   2, max: -1,
+			types: [][]DataType{{Struct}, {Protocol}},
+			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
+				return &[]dataType{Implement(ds, params)}
+			},
+		},
+		{
+			name:  "implements?",
+			usage: "value protocol",
+			doc:   "true if the value is a struct with the methods of the protocol",
+			min:   2, max: 2,
+			types: [][]DataType{nil, {Protocol}},
+			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
+				return &[]dataType{{dataType: Bool, value: Implements(ds, params[0], params[1])}}
+			},
+		},
+		{
+			name:  "enum",
+			usage: "name tag...",
+			doc:   "declares an enum with the tags, named as name/tag. A tag given as (tag field...) is made by calling name/tag",
+			min:   1, max: -1,
+			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
+				DefEnum(ds, scopes, params)
+				return nil
+			},
+		},
+		{
+			name:  "variant",
+			usage: "name tag field...",
+			doc:   "adds a tag carrying the fields to the enum name, declaring it if needed",
+			min:   2, max: -1,
+			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
+				DefVariant(ds, scopes, params)
+				return nil
+			},
+		},
+		{
+			name:  "defstruct",
+			usage: "name field... (func method params... body)...",
+			doc:   "declares a struct type with the fields and methods, and a function of the same name making one",
+			min:   1, max: -1,
+			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
+				DefStruct(ds, scopes, params)
+				return nil
+			},
+		},
+		{
+			name:  "shift",
+			usage: "list",
+			doc:   "removes and returns the first item of the list in a variable",
+			min:   1, max: 1,
+			types: [][]DataType{listTypes},
+			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
 				return &[]dataType{Shift(ds, params[0])}
 			},
 		},
 		{
-			name: ".",
+			name:  ".",
+			usage: "struct method args...",
+			doc:   "calls a function attribute of the struct with the struct as its first param",
+			min:   2, max: -1,
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) < 2 {
-					log.Fatal("Invalid number of parameters to \".\". Expected 2 or more found ", len(params))
-				}
 				return CallProp(ds, scopes, params)
 			},
 		},
 		{
-			name: "while",
+			name:  "while",
+			usage: "condition body",
+			doc:   "runs body while condition is true",
+			min:   2, max: 2,
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
 				return WhileLoop(ds, scopes, params)
 			},
 		},
 		{
-			name: "++",
+			name:  "++",
+			usage: "name",
+			doc:   "adds 1 to the variable",
+			min:   1, max: 1,
+			types: [][]DataType{nameTypes},
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) != 1 {
-					log.Fatal("Invalid number of parameters to \"++\", expected 1 found ", len(params))
-				}
 				return &[]dataType{AddOne(ds, params[0])}
 			},
 		},
 		{
-			name: "--",
+			name:  "--",
+			usage: "name",
+			doc:   "subtracts 1 from the variable",
+			min:   1, max: 1,
+			types: [][]DataType{nameTypes},
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) != 1 {
-					log.Fatal("Invalid number of parameters to \"--\", expected 1 found ", len(params))
-				}
 				return &[]dataType{SubOne(ds, params[0])}
 			},
 		},
 		{
-			name: "+=",
+			name:  "+=",
+			usage: "name value",
+			doc:   "adds value to the variable",
+			min:   2, max: 2,
+			types: [][]DataType{nameTypes, numberTypes},
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) != 2 {
-					log.Fatal("Invalid number of parameters to \"+=\", expected 2 found ", len(params))
-				}
 				return &[]dataType{AddMany(ds, params[0], params[1])}
 			},
 		},
 		{
-			name: "-=",
+			name:  "-=",
+			usage: "name value",
+			doc:   "subtracts value from the variable",
+			min:   2, max: 2,
+			types: [][]DataType{nameTypes, numberTypes},
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) != 2 {
-					log.Fatal("Invalid number of parameters to \"-=\", expected 2 found ", len(params))
-				}
 				return &[]dataType{SubMany(ds, params[0], params[1])}
 			},
 		},
 		{
-			name: "require",
+			name:  "require",
+			usage: "path [as name]",
+			doc:   "loads a module, binding it to name or putting its exports in scope",
+			min:   1, max: 3,
+			types: [][]DataType{stringTypes},
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) != 1 {
-					log.Fatal("Invalid number of parameters to \"require\", expected 1 found ", len(params))
-				}
-
-				file := GetFile(ds, params[0])
-				tokens := Tokenize(string(file))
-				Eval(ds, tokens, scopes-1)
+				Require(ds, scopes, params)
 				return nil
 			},
 		},
 		{
-			name: "from-char-code",
+			name:  "from-char-code",
+			usage: "code",
+			doc:   "string of the character with the char code",
+			min:   1, max: 1,
+			types: [][]DataType{intTypes},
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) != 1 {
-					log.Fatal("Invalid number of parameters to \"from-char-code\", expected 1 found ", len(params))
-				}
 				return &[]dataType{FromCharCode(ds, params[0])}
 			},
 		},
 		{
-			name: "char-code-from",
+			name:  "char-code-from",
+			usage: "char",
+			doc:   "char code of a string with one character",
+			min:   1, max: 1,
+			types: [][]DataType{stringTypes},
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) != 1 {
-					log.Fatal("Invalid number of parameters to \"char-code-from\", expected 1 found ", len(params))
-				}
 				return &[]dataType{CharCodeFrom(ds, params[0])}
 			},
 		},
 		{
-			name: "split",
+			name:  "split",
+			usage: "string [sep]",
+			doc:   "list of the parts of the string between each sep, or of its characters",
+			min:   1, max: 2,
+			types: [][]DataType{stringTypes, stringTypes},
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
 				if len(params) == 1 {
 					return &[]dataType{Split(ds, params[0], dataType{dataType: String, value: ""})}
-				} else if len(params) == 2 {
-					return &[]dataType{Split(ds, params[0], params[1])}
-				} else {
-					log.Fatal("Invalid number of parameters to \"split\", expected 1 or 2 found ", len(params))
-					return nil
 				}
+				return &[]dataType{Split(ds, params[0], params[1])}
 			},
 		},
 		{
-			name: "is-letter",
+			name:  "is-letter",
+			usage: "char",
+			doc:   "true if the string is a single letter",
+			min:   1, max: 1,
+			types: [][]DataType{stringTypes},
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) != 1 {
-					log.Fatal("Invalid number of parameters to \"is-letter\", expected 1 found ", len(params))
-				}
-
 				return &[]dataType{IsLetter(ds, params[0])}
 			},
 		},
 		{
-			name: "keys",
+			name:  "keys",
+			usage: "value",
+			doc:   "list of the attribute names of a struct or keys of a map",
+			min:   1, max: 1,
+			types: [][]DataType{{Struct, Map}},
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) != 1 {
-					log.Fatal("Invalid number of parameters to \"keys\", expected 1 found ", len(params))
-				}
-
 				return &[]dataType{GetKeys(ds, params[0])}
 			},
 		},
 		{
-			name: "values",
+			name:  "values",
+			usage: "value",
+			doc:   "list of the attribute values of a struct or values of a map",
+			min:   1, max: 1,
+			types: [][]DataType{{Struct, Map}},
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) != 1 {
-					log.Fatal("Invalid number of parameters to \"values\", expected 1 found ", len(params))
-				}
-
 				return &[]dataType{GetValues(ds, params[0])}
 			},
 		},
 		{
-			name: "floor",
+			name:  "floor",
+			usage: "number",
+			doc:   "number rounded down to an int",
+			min:   1, max: 1,
+			types: [][]DataType{numberTypes},
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) != 1 {
-					log.Fatal("Invalid number of parameters to \"floor\", expected 1 found ", len(params))
-				}
-
 				return &[]dataType{Floor(ds, params[0])}
 			},
 		},
 		{
-			name: "ceil",
+			name:  "ceil",
+			usage: "number",
+			doc:   "number rounded up to an int",
+			min:   1, max: 1,
+			types: [][]DataType{numberTypes},
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) != 1 {
-					log.Fatal("Invalid number of parameters to \"ceil\", expected 1 found ", len(params))
-				}
-
 				return &[]dataType{Ceil(ds, params[0])}
 			},
 		},
 		{
-			name: "float",
+			name:  "float",
+			usage: "number",
+			doc:   "number as a float",
+			min:   1, max: 1,
+			types: [][]DataType{numberTypes},
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) != 1 {
-					log.Fatal("Invalid number of parameters to \"float\", expected 1 found ", len(params))
-				}
 				return &[]dataType{CastFloat(ds, params[0])}
 			},
 		},
 		{
-			name: "int",
+			name:  "int",
+			usage: "number",
+			doc:   "number as an int, rounded toward zero",
+			min:   1, max: 1,
+			types: [][]DataType{numberTypes},
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) != 1 {
-					log.Fatal("Invalid number of parameters to \"int\", expected 1 found ", len(params))
-				}
 				return &[]dataType{CastInt(ds, params[0])}
 			},
 		},
 		{
-			name: "string",
+			name:  "string",
+			usage: "value",
+			doc:   "value as a string",
+			min:   1, max: 1,
 			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
-				if len(params) != 1 {
-					log.Fatal("Invalid number of parameters to \"string\", expected 1 found ", len(params))
-				}
 				return &[]dataType{CastString(ds, params[0])}
 			},
 		},
-	}
+		{
+			name:  "list",
+			usage: "values...",
+			doc:   "list of the values",
+			min:   0, max: -1,
+			shadowable: true,
+			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
+				res := make([]dataType, len(params))
+				for i, v := range params {
+					res[i] = GetDsValue(ds, v)
+				}
+				return &[]dataType{{dataType: List, value: res}}
+			},
+		},
+		{
+			name:  "symbol",
+			usage: "name",
+			doc:   "symbol with the name in the string",
+			min:   1, max: 1,
+			types:      [][]DataType{stringTypes},
+			shadowable: true,
+			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
+				name := GetDsValue(ds, params[0])
+				return &[]dataType{{dataType: Symbol, value: name.value}}
+			},
+		},
+		{
+			name:  "macro",
+			usage: "name params... body",
+			doc:   "defines a macro, called with its params as code and returning the code to run",
+			min:   2, max: -1,
+			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
+				MakeMacro(ds, scopes, params[0], params[1:])
+				return nil
+			},
+		},
+		{
+			name:  "hash-map",
+			usage: "key value...",
+			doc:   "map with the entries given as pairs of keys and values",
+			min:   0, max: -1,
+			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
+				return &[]dataType{MakeMap(ds, params)}
+			},
+		},
+		{
+			name:  "map-get",
+			usage: "map key [default]",
+			doc:   "value at key in the map, default or nil if it is not set",
+			min:   2, max: 3,
+			types: [][]DataType{mapTypes},
+			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
+				val, ok := GetMap(ds, "map-get", params[0]).Get(GetDsValue(ds, params[1]))
+				if !ok && len(params) == 3 {
+					val = GetDsValue(ds, params[2])
+				}
+				return &[]dataType{val}
+			},
+		},
+		{
+			name:  "map-set",
+			usage: "map key value",
+			doc:   "sets the value at key in the map",
+			min:   3, max: 3,
+			types: [][]DataType{mapTypes},
+			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
+				GetMap(ds, "map-set", params[0]).Set(GetDsValue(ds, params[1]), GetDsValue(ds, params[2]))
+				return nil
+			},
+		},
+		{
+			name:  "map-has",
+			usage: "map key",
+			doc:   "true if key is set in the map",
+			min:   2, max: 2,
+			types: [][]DataType{mapTypes},
+			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
+				_, ok := GetMap(ds, "map-has", params[0]).Get(GetDsValue(ds, params[1]))
+				return &[]dataType{{dataType: Bool, value: ok}}
+			},
+		},
+		{
+			name:  "map-delete",
+			usage: "map key",
+			doc:   "removes key from the map, true if it was set",
+			min:   2, max: 2,
+			types: [][]DataType{mapTypes},
+			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
+				removed := GetMap(ds, "map-delete", params[0]).Delete(GetDsValue(ds, params[1]))
+				return &[]dataType{{dataType: Bool, value: removed}}
+			},
+		},
+		{
+			name:  "export",
+			usage: "names...",
+			doc:   "limits the names other files can use from this module",
+			min:   0, max: -1,
+			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
+				Export(ds, params)
+				return nil
+			},
+		},
+		{
+			name:  "help",
+			usage: "[name]",
+			doc:   "usage and doc of a builtin, or the names of every builtin",
+			min:   0, max: 1,
+			fn: func(ds *dataStore, scopes int, params []dataType) *[]dataType {
+				return &[]dataType{Help(ds, params)}
+			},
+		},
+	})
 }
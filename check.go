@@ -0,0 +1,806 @@
+package blisp
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// what the checker knows about a name
+type checkName struct {
+	// params of the function or macro the name holds, nil if not known
+	fn    *function
+	macro bool
+	// set when the name holds a module from (require "file" as name), or an
+	// enum with its tags as names
+	module *checkModule
+	// tags of an enum in the order they were declared
+	tags []string
+}
+
+// names defined in a function body or at the top level of a file, names
+// defined anywhere in a body are visible in all of it
+type checkScope struct {
+	names  map[string]*checkName
+	parent *checkScope
+	// return type of the function the scope is the body of
+	returns []DataType
+}
+
+func newCheckScope(parent *checkScope) *checkScope {
+	return &checkScope{names: make(map[string]*checkName), parent: parent}
+}
+
+func (s *checkScope) lookup(name string) *checkName {
+	for ; s != nil; s = s.parent {
+		if n, ok := s.names[name]; ok {
+			return n
+		}
+	}
+	return nil
+}
+
+// names a required module defines
+type checkModule struct {
+	scope *checkScope
+	// names given to export, nil when every name is exported
+	exports []string
+	// the module could not be loaded, any name is allowed so one missing
+	// file is only reported once
+	unknown bool
+}
+
+func (m *checkModule) lookup(name string) (*checkName, bool) {
+	if m.unknown {
+		return &checkName{}, true
+	}
+	if m.exports != nil && !StrArrIncludes(m.exports, name) {
+		return nil, false
+	}
+	n, ok := m.scope.names[name]
+	return n, ok
+}
+
+// finds mistakes in a program without running it
+type checker struct {
+	ds      *dataStore
+	errs    []*BlispError
+	modules map[string]*checkModule
+}
+
+// CheckString reports the mistakes found in code without running it, like
+// undefined names, calls with the wrong number of args and unreachable code.
+// Unreachable code is reported with the Warning kind.
+func (in *Interpreter) CheckString(code string) []*BlispError {
+	return in.check("<string>", code)
+}
+
+// CheckFile is CheckString for a source file, the error is only set when the
+// file can not be read.
+func (in *Interpreter) CheckFile(path string) ([]*BlispError, error) {
+	dat, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		// requires are found relative to the file
+		in.ds.files = append(in.ds.files, abs)
+		defer func() { in.ds.files = in.ds.files[:len(in.ds.files)-1] }()
+	}
+	return in.check(path, string(dat)), nil
+}
+
+func (in *Interpreter) check(file string, code string) []*BlispError {
+	nodes, err := parse(file, code)
+	if err != nil {
+		return []*BlispError{err.(*BlispError)}
+	}
+	c := &checker{ds: in.ds, modules: make(map[string]*checkModule)}
+	s := newCheckScope(c.globals())
+	// anything not reported where it was found ends the check
+	c.try(position{}, func() {
+		c.define(nodes, s)
+		c.checkBlock(nodes, s)
+	})
+	return c.errs
+}
+
+// names already defined in the interpreter, like ones from SetGlobal
+func (c *checker) globals() *checkScope {
+	s := newCheckScope(nil)
+	global := c.ds.frames[0]
+	for name, v := range global.vars {
+		n := &checkName{}
+		if v.data.dataType == Func && v.data.value.(function).native == nil {
+			f := v.data.value.(function)
+			n.fn = &f
+		}
+		s.names[name] = n
+	}
+	for name, f := range global.funcs {
+		f := f
+		s.names[name] = &checkName{fn: &f}
+	}
+	return s
+}
+
+func (c *checker) report(kind ErrorKind, pos position, args ...any) {
+	err := &BlispError{Kind: kind, Message: fmt.Sprint(args...)}
+	err.setPos(pos)
+	c.errs = append(c.errs, err)
+}
+
+// runs f, reporting an error it raises at pos instead of stopping
+func (c *checker) try(pos position, f func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			err := toBlispError(r)
+			err.setPos(pos)
+			c.errs = append(c.errs, err)
+		}
+	}()
+	f()
+}
+
+// name of the function n calls, empty if it is not a call to a name
+func callName(n *node) string {
+	if n.nodeType != CallNode || len(n.children) == 0 || n.children[0].nodeType != IdentNode {
+		return ""
+	}
+	return n.children[0].value.value.(string)
+}
+
+// adds the names the nodes define to s, the bodies of functions they make are
+// left for checkFunc
+func (c *checker) define(nodes []*node, s *checkScope) {
+	for _, n := range nodes {
+		switch n.nodeType {
+		case BodyNode:
+			c.define(n.children, s)
+		case CallNode:
+			c.defineCall(n, s)
+		}
+	}
+}
+
+func (c *checker) defineCall(n *node, s *checkScope) {
+	children := n.children
+	switch callName(n) {
+	case "var", "const":
+		if len(children) == 3 {
+			c.defineTarget(children[1], s)
+			if children[1].nodeType == IdentNode && callName(children[2]) == "func" {
+				s.names[children[1].value.value.(string)].fn = c.signature(children[2])
+			}
+		}
+	case "func", "macro":
+		if len(children) > 2 && children[1].nodeType == IdentNode && children[1].value.value != "_" {
+			s.names[children[1].value.value.(string)] = &checkName{fn: c.signature(n), macro: callName(n) == "macro"}
+		}
+		return
+	case "loop":
+		for i := 2; i < len(children)-1; i++ {
+			c.defineTarget(children[i], s)
+		}
+	case "match":
+		for _, arm := range children[2:] {
+			if arm.nodeType == BodyNode && len(arm.children) > 0 {
+				c.definePattern(arm.children[0], s)
+			}
+		}
+	case "require":
+		c.defineRequire(n, s)
+		return
+	case "defstruct":
+		if len(children) > 1 && children[1].nodeType == IdentNode {
+			s.names[children[1].value.value.(string)] = &checkName{fn: c.recordSignature(n)}
+		}
+		return
+	case "protocol":
+		if len(children) > 1 && children[1].nodeType == IdentNode {
+			s.names[children[1].value.value.(string)] = &checkName{}
+		}
+		return
+	case "enum", "variant":
+		c.defineUnion(n, s)
+		return
+	}
+	c.define(children[1:], s)
+}
+
+// names the enum or variant call n declares, its tags are looked up like the
+// names of a module
+func (c *checker) defineUnion(n *node, s *checkScope) {
+	children := n.children
+	if len(children) < 3 || children[1].nodeType != IdentNode {
+		return
+	}
+	name := children[1].value.value.(string)
+	u := s.names[name]
+	if u == nil || u.module == nil {
+		u = &checkName{module: &checkModule{scope: newCheckScope(nil)}}
+		s.names[name] = u
+	}
+	add := func(t *node, fields []*node) {
+		if t.nodeType != IdentNode {
+			return
+		}
+		tagName := t.value.value.(string)
+		known := &checkName{}
+		if len(fields) > 0 {
+			c.try(t.pos, func() {
+				params, _ := GetParams(callName(n), paramData(fields))
+				known.fn = &function{name: name + "/" + tagName, params: params}
+			})
+		}
+		u.module.scope.names[tagName] = known
+		u.tags = append(u.tags, tagName)
+	}
+	if callName(n) == "variant" {
+		add(children[2], children[3:])
+		return
+	}
+	for _, child := range children[2:] {
+		if child.nodeType == BodyNode && len(child.children) > 0 {
+			add(child.children[0], child.children[1:])
+		} else {
+			add(child, nil)
+		}
+	}
+}
+
+// name bound by var, loop or a param, either an Ident or a pattern
+func (c *checker) defineTarget(n *node, s *checkScope) {
+	if n.nodeType == IdentNode && n.value.value != "_" {
+		s.names[n.value.value.(string)] = &checkName{}
+	} else if n.nodeType == LiteralNode && n.value.dataType == Pattern {
+		for _, name := range n.value.value.(*pattern).names() {
+			s.names[name] = &checkName{}
+		}
+	}
+}
+
+// names bound by a match pattern
+func (c *checker) definePattern(n *node, s *checkScope) {
+	switch n.nodeType {
+	case IdentNode:
+		// Type/tag matches a tag instead of binding a name
+		if name := n.value.value.(string); name != "&" && !strings.Contains(name, "/") {
+			c.defineTarget(n, s)
+		}
+	case LiteralNode:
+		c.defineTarget(n, s)
+	case ListNode:
+		for _, child := range n.children {
+			c.definePattern(child, s)
+		}
+	case CallNode:
+		// (Type pattern) or (Type/tag pattern...)
+		for _, child := range n.children[1:] {
+			c.definePattern(child, s)
+		}
+	}
+}
+
+func (c *checker) defineRequire(n *node, s *checkScope) {
+	args := n.children[1:]
+	if len(args) == 0 || args[0].nodeType != LiteralNode || args[0].value.dataType != String {
+		return
+	}
+	var m *checkModule
+	c.try(n.pos, func() {
+		m = c.loadModule(args[0].value.value.(string))
+	})
+	if m == nil {
+		m = &checkModule{unknown: true}
+	}
+	if len(args) == 3 && args[2].nodeType == IdentNode {
+		s.names[args[2].value.value.(string)] = &checkName{module: m}
+		return
+	}
+	if m.unknown {
+		return
+	}
+	for name, v := range m.scope.names {
+		if m.exports == nil || StrArrIncludes(m.exports, name) {
+			s.names[name] = v
+		}
+	}
+}
+
+// names defined by the module for (require name), each module is read once
+func (c *checker) loadModule(name string) *checkModule {
+	path := FindModule(c.ds, name)
+	if m, ok := c.modules[path]; ok {
+		return m
+	}
+	m := &checkModule{scope: newCheckScope(nil)}
+	c.modules[path] = m
+	nodes := ParseTokens(Tokenize(path, string(readModule(path))))
+	c.ds.files = append(c.ds.files, path)
+	defer func() { c.ds.files = c.ds.files[:len(c.ds.files)-1] }()
+	c.define(nodes, m.scope)
+	for _, n := range nodes {
+		if callName(n) != "export" {
+			continue
+		}
+		if m.exports == nil {
+			m.exports = []string{}
+		}
+		for _, child := range n.children[1:] {
+			if child.nodeType == IdentNode {
+				m.exports = append(m.exports, child.value.value.(string))
+			}
+		}
+	}
+	return m
+}
+
+// params of the function made by the func or macro call n
+func (c *checker) signature(n *node) *function {
+	if len(n.children) < 3 {
+		return nil
+	}
+	var f *function
+	c.try(n.pos, func() {
+		data, returns := ReturnType(callName(n), paramData(n.children[2:len(n.children)-1]))
+		params, rest := GetParams(callName(n), data)
+		f = &function{name: n.children[1].value.value.(string), params: params, rest: rest, returns: returns}
+	})
+	return f
+}
+
+// params of the constructor made by the defstruct call n
+func (c *checker) recordSignature(n *node) *function {
+	var f *function
+	c.try(n.pos, func() {
+		params, _ := GetParams("defstruct", paramData(recordFields(n)))
+		f = &function{name: n.children[1].value.value.(string), params: params}
+	})
+	return f
+}
+
+// fields given to the defstruct call n, the rest of its args are methods and
+// implements clauses
+func recordFields(n *node) []*node {
+	fields := []*node{}
+	for _, child := range n.children[2:] {
+		if child.nodeType == BodyNode && len(child.children) > 0 && child.children[0].nodeType == IdentNode {
+			if name := child.children[0].value.value; name == "func" || name == "implements" {
+				continue
+			}
+		}
+		fields = append(fields, child)
+	}
+	return fields
+}
+
+// checks a block, code after a return, break or exit is never run
+func (c *checker) checkBlock(nodes []*node, s *checkScope) {
+	warned := false
+	for i, n := range nodes {
+		c.checkNode(n, s)
+		name := callName(n)
+		if !warned && i < len(nodes)-1 && (name == "return" || name == "break" || name == "exit") {
+			c.report(Warning, nodes[i+1].pos, "Unreachable code after \"", name, "\"")
+			warned = true
+		}
+	}
+}
+
+func (c *checker) checkNode(n *node, s *checkScope) {
+	switch n.nodeType {
+	case IdentNode:
+		c.checkName(n, s)
+	case ListNode:
+		c.checkNodes(n.children, s)
+	case BodyNode:
+		c.checkBlock(n.children, s)
+	case CallNode:
+		c.checkCall(n, s)
+	}
+}
+
+func (c *checker) checkNodes(nodes []*node, s *checkScope) {
+	for _, n := range nodes {
+		c.checkNode(n, s)
+	}
+}
+
+func (c *checker) checkName(n *node, s *checkScope) {
+	name := n.value.value.(string)
+	if s.lookup(name) != nil || IsBuiltin(c.ds, name) != nil || StrArrIncludes(keywords, name) || name == "_" {
+		return
+	}
+	if _, ok := c.qualified(name, s); ok {
+		return
+	}
+	c.report(NameError, n.pos, "Undefined name \"", name, "\"")
+}
+
+// what is known about q/name, ok is false if q is not a module defining name
+func (c *checker) qualified(name string, s *checkScope) (*checkName, bool) {
+	i := strings.Index(name, "/")
+	if i <= 0 || i == len(name)-1 {
+		return nil, false
+	}
+	q := s.lookup(name[:i])
+	if q == nil || q.module == nil {
+		return nil, false
+	}
+	return q.module.lookup(name[i+1:])
+}
+
+// checks a reserved name is not being defined
+func (c *checker) checkDefine(n *node, kind string) {
+	names := []string{}
+	if n.nodeType == IdentNode {
+		names = append(names, n.value.value.(string))
+	} else if n.nodeType == LiteralNode && n.value.dataType == Pattern {
+		names = n.value.value.(*pattern).names()
+	}
+	for _, name := range names {
+		if IsReserved(c.ds, name) {
+			c.report(NameError, n.pos, kind, " name \"", name, "\" is reserved")
+		}
+	}
+}
+
+// builtin called by name, nil if there is none or if name is a shadowable
+// builtin with a function or macro of the same name in scope
+func (c *checker) builtin(name string, s *checkScope) *builtin {
+	b := IsBuiltin(c.ds, name)
+	if b != nil && b.shadowable {
+		if known := s.lookup(name); known != nil && known.fn != nil {
+			return nil
+		}
+	}
+	return b
+}
+
+func (c *checker) checkCall(n *node, s *checkScope) {
+	if len(n.children) == 0 {
+		return
+	}
+	head := n.children[0]
+	args := n.children[1:]
+	if head.nodeType != IdentNode {
+		c.checkNode(head, s)
+		c.checkNodes(args, s)
+		return
+	}
+	name := head.value.value.(string)
+	if b := c.builtin(name, s); b != nil {
+		if msg := b.arityError(len(args)); msg != "" {
+			c.report(ArityError, n.pos, msg)
+		}
+		for i, arg := range args {
+			if types := b.paramTypes(i); types != nil {
+				c.checkType(arg, s, types, "Error in \"", name, "\", expected ", typesStr(types))
+			}
+		}
+		if name == "return" && len(args) == 1 {
+			c.checkResult(args[0], s)
+		}
+		c.checkBuiltin(n, s)
+		return
+	}
+	known := s.lookup(name)
+	if known == nil {
+		known, _ = c.qualified(name, s)
+	}
+	if known == nil {
+		c.report(NameError, head.pos, "Unknown function \"", name, "\"")
+		c.checkNodes(args, s)
+		return
+	}
+	if known.fn != nil {
+		if msg := paramCountError(name, *known.fn, len(args)); msg != "" {
+			c.report(ArityError, n.pos, msg)
+		}
+		for i, p := range known.fn.params {
+			if i < len(args) && p.typ != nil && !p.typ.named() && !known.macro {
+				c.checkType(args[i], s, []DataType{p.typ.t}, "Error in \"", name, "\", expected param ", p.name, " to be ", p.typ)
+			}
+		}
+	}
+	// the args of a macro are code, not values
+	if !known.macro {
+		c.checkNodes(args, s)
+	}
+}
+
+// type n has when it is evaluated, ok is false if it is not known before
+// running, like the value of a variable
+func (c *checker) typeOf(n *node, s *checkScope) (DataType, bool) {
+	switch n.nodeType {
+	case LiteralNode:
+		if isStructLiteral(n) {
+			return Struct, true
+		}
+		return n.value.dataType, n.value.dataType != Pattern
+	case ListNode:
+		return List, true
+	case QuoteNode:
+		return nodeToData(n.children[0]).dataType, true
+	case CallNode:
+		// calls to functions with a return type
+		if name := callName(n); name != "" && c.builtin(name, s) == nil {
+			known := s.lookup(name)
+			if known == nil {
+				known, _ = c.qualified(name, s)
+			}
+			if known != nil && known.fn != nil && !known.macro && known.fn.returns != nil && !known.fn.returns.named() {
+				return known.fn.returns.t, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// reports n if its type is known and not one of types, msg is the start of
+// the error
+func (c *checker) checkType(n *node, s *checkScope, types []DataType, msg ...any) {
+	t, ok := c.typeOf(n, s)
+	// a name is checked as written
+	if n.nodeType == IdentNode && typeIncludes(types, Ident) {
+		t, ok = Ident, true
+	}
+	if ok && !typeIncludes(types, t) {
+		c.report(TypeError, n.pos, append(msg, " found ", dataTypes[t])...)
+	}
+}
+
+// checks a value returned by the function s is the body of against its
+// return type, the value of an if, cond, when, unless or match is the value
+// of one of its branches so each is checked
+func (c *checker) checkResult(n *node, s *checkScope) {
+	if s.returns == nil {
+		return
+	}
+	switch callName(n) {
+	case "if", "when", "unless":
+		for i := 2; i < len(n.children); i++ {
+			c.checkResult(lastNode(n.children[i]), s)
+		}
+		return
+	case "cond":
+		for _, clause := range n.children[1:] {
+			if clause.nodeType == BodyNode && len(clause.children) > 1 {
+				c.checkResult(lastNode(clause), s)
+			}
+		}
+		return
+	case "match":
+		for i := 2; i < len(n.children); i++ {
+			if arm := n.children[i]; arm.nodeType == BodyNode && (len(arm.children) == 2 || len(arm.children) == 4) {
+				c.checkResult(lastNode(arm.children[len(arm.children)-1]), s)
+			}
+		}
+		return
+	}
+	c.checkType(n, s, s.returns, "Expected return type ", typesStr(s.returns))
+}
+
+// the node giving the value of n, the last expr if n is a body
+func lastNode(n *node) *node {
+	for n.nodeType == BodyNode && len(n.children) > 0 {
+		n = n.children[len(n.children)-1]
+	}
+	return n
+}
+
+// warns about a match on the tags of an enum that leaves some out and has no
+// arm matching anything else, arms with a guard do not cover their tag
+func (c *checker) checkExhaustive(n *node, s *checkScope) {
+	var union *checkName
+	unionName := ""
+	covered := map[string]bool{}
+	for _, arm := range n.children[2:] {
+		if arm.nodeType != BodyNode || len(arm.children) == 0 {
+			continue
+		}
+		p := arm.children[0]
+		isCall := p.nodeType == CallNode && len(p.children) > 0
+		if isCall {
+			p = p.children[0]
+		}
+		if p.nodeType != IdentNode {
+			continue
+		}
+		name := p.value.value.(string)
+		i := strings.Index(name, "/")
+		if i <= 0 {
+			if !isCall && len(arm.children) == 2 {
+				// _ or a name matches anything
+				return
+			}
+			continue
+		}
+		q := s.lookup(name[:i])
+		if q == nil || q.tags == nil {
+			continue
+		}
+		if union == nil {
+			union, unionName = q, name[:i]
+		}
+		if len(arm.children) == 2 {
+			covered[name[i+1:]] = true
+		}
+	}
+	if union == nil {
+		return
+	}
+	missing := []string{}
+	for _, t := range union.tags {
+		if !covered[t] {
+			missing = append(missing, unionName+"/"+t)
+		}
+	}
+	if len(missing) > 0 {
+		c.report(Warning, n.pos, "\"match\" on ", unionName, " is missing ", strings.Join(missing, " "))
+	}
+}
+
+// checks the args of a builtin, some of them are names instead of values
+func (c *checker) checkBuiltin(n *node, s *checkScope) {
+	args := n.children[1:]
+	switch callName(n) {
+	case "var", "const":
+		if len(args) == 2 {
+			c.checkDefine(args[0], "Variable")
+			c.checkNode(args[1], s)
+		}
+	case "func", "macro":
+		c.checkFunc(n, s)
+	case "set":
+		if len(args) == 3 {
+			// (set obj key value)
+			args = []*node{args[0], args[2]}
+		}
+		c.checkNodes(args, s)
+	case "get", "remove", ".":
+		// the key can be an attribute name
+		for i, arg := range args {
+			if i != 1 || arg.nodeType != IdentNode {
+				c.checkNode(arg, s)
+			}
+		}
+	case "struct":
+		for i := 1; i < len(args); i += 2 {
+			c.checkNode(args[i], s)
+		}
+	case "extend":
+		for i := 0; i < len(args); i += 2 {
+			c.checkNode(args[i], s)
+		}
+	case "super":
+		// the method name is an attribute
+		c.checkNodes(args[1:], s)
+	case "loop":
+		for i, arg := range args {
+			if i > 0 && i < len(args)-1 && (arg.nodeType == IdentNode || arg.nodeType == LiteralNode) {
+				c.checkDefine(arg, "Variable")
+				continue
+			}
+			c.checkNode(arg, s)
+		}
+	case "match":
+		if len(args) > 0 {
+			c.checkNode(args[0], s)
+		}
+		for _, arm := range args[1:] {
+			if arm.nodeType == BodyNode && len(arm.children) > 1 {
+				// the pattern only has names and literals
+				c.checkNodes(arm.children[1:], s)
+			}
+		}
+		c.checkExhaustive(n, s)
+	case "cond":
+		for _, clause := range args {
+			if clause.nodeType == BodyNode && len(clause.children) > 0 {
+				test := clause.children[0]
+				if test.nodeType != IdentNode || test.value.value != "else" {
+					c.checkNode(test, s)
+				}
+				c.checkBlock(clause.children[1:], s)
+			}
+		}
+	case "defstruct":
+		c.checkRecord(n, s)
+	case "enum", "variant":
+		// tags and fields
+	case "protocol":
+		// method names and params
+	case "require", "help":
+	default:
+		c.checkNodes(args, s)
+	}
+}
+
+// checks the params and body of a function in a scope of its own
+func (c *checker) checkFunc(n *node, s *checkScope) {
+	children := n.children
+	if len(children) < 3 {
+		return
+	}
+	kind := "Function"
+	if callName(n) == "macro" {
+		kind = "Macro"
+	}
+	c.checkDefine(children[1], kind)
+	c.checkFuncIn(children, newCheckScope(s))
+}
+
+// checks the params and body of the func call with children in fs
+func (c *checker) checkFuncIn(children []*node, fs *checkScope) {
+	params := children[2 : len(children)-1]
+	for i, param := range params {
+		if param.nodeType == IdentNode && param.value.value == "->" {
+			// -> Type ends the params
+			if t := children[len(children)-2]; t.nodeType == IdentNode {
+				if rt, ok := typeByName(t.value.value.(string)); ok {
+					fs.returns = []DataType{rt}
+				}
+			}
+			params = params[:i]
+			break
+		}
+	}
+	c.checkParams(params, fs)
+	body := children[len(children)-1]
+	if body.nodeType == BodyNode {
+		c.define(body.children, fs)
+		c.checkBlock(body.children, fs)
+		// a call ending the body gives the value it returns
+		if l := len(body.children); l > 0 && body.children[l-1].nodeType == CallNode {
+			c.checkResult(body.children[l-1], fs)
+		}
+	}
+}
+
+// checks the defaults of params and adds their names to fs
+func (c *checker) checkParams(params []*node, fs *checkScope) {
+	for _, param := range params {
+		if param.nodeType == BodyNode && len(param.children) > 0 {
+			// (name Type default)
+			for _, child := range param.children[1:] {
+				if child.nodeType == IdentNode {
+					if _, ok := typeByName(child.value.value.(string)); ok {
+						continue
+					}
+				}
+				c.checkNode(child, fs)
+			}
+			param = toPattern(param.children[0])
+		}
+		if param.nodeType == IdentNode && strings.HasPrefix(param.value.value.(string), "&") {
+			continue
+		}
+		c.checkDefine(param, "Variable")
+		c.defineTarget(param, fs)
+	}
+}
+
+// checks the fields and methods of a defstruct, methods can use this
+func (c *checker) checkRecord(n *node, s *checkScope) {
+	if len(n.children) < 2 {
+		return
+	}
+	c.checkDefine(n.children[1], "Struct")
+	c.checkParams(recordFields(n), newCheckScope(s))
+	for _, child := range n.children[2:] {
+		if child.nodeType != BodyNode {
+			continue
+		}
+		v := dataType{dataType: Body, value: child.children}
+		if nodes, ok := recordClause(v, "func"); ok && len(nodes) > 2 {
+			ms := newCheckScope(s)
+			ms.names["this"] = &checkName{}
+			c.checkFuncIn(nodes, ms)
+		} else if nodes, ok := recordClause(v, "implements"); ok {
+			c.checkNodes(nodes[1:], s)
+		}
+	}
+}
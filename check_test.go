@@ -0,0 +1,24 @@
+package blisp
+
+import "testing"
+
+// returned values whose type is known are checked against the return type
+func TestCheckReturns(t *testing.T) {
+	tests := []struct {
+		code string
+		want int
+	}{
+		{`(func f -> Int (body (return "a")))`, 1},
+		{`(func f -> Int (body (return 1)))`, 0},
+		{`(func f n -> String (body (if (eq n 0) 1 "x")))`, 1},
+		{`(func f n -> Int (body (return (cond ((eq n 0) "zero") (else 1)))))`, 1},
+		{`(func f n -> Int (body (match n (0 "zero") (_ (body (print n) "other")))))`, 2},
+		{`(func f n -> Int (body (when (eq n 0) 1)))`, 0},
+		{`(func f n (body (if (eq n 0) 1 "x")))`, 0},
+	}
+	for _, test := range tests {
+		if got := New().CheckString(test.code); len(got) != test.want {
+			t.Errorf("%s: got %d problems %v, want %d", test.code, len(got), got, test.want)
+		}
+	}
+}
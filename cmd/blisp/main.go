@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime/debug"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/JacksonO123/blisp"
+)
+
+var benchmark bool = false
+
+// calls that are not tail calls use go stack, the default limit runs out
+// long before the call depth blisp allows, this is the most go allows
+const maxStack = 2_000_000_000
+
+func main() {
+	debug.SetMaxStack(maxStack)
+	args := os.Args[1:]
+	scanner := bufio.NewScanner(os.Stdin)
+	fileName := ""
+	interpreter := blisp.New()
+	if blisp.StrArrIncludes(args, "-vm") {
+		interpreter.UseVM(true)
+		args = removeFlag(args, "-vm")
+	}
+	if blisp.StrArrIncludes(args, "-exact") {
+		interpreter.UseExact(true)
+		args = removeFlag(args, "-exact")
+	}
+	if len(args) > 0 && args[0] == "check" {
+		os.Exit(check(interpreter, args[1:]))
+	}
+	if len(args) > 0 {
+		fileName = withExt(args[0])
+	} else {
+		// repl
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT)
+		go func() {
+			<-sigs
+			fmt.Println(" | Closing...")
+			fmt.Println()
+			os.Exit(0)
+		}()
+		for {
+			fmt.Print("> ")
+			line := ""
+			scanner.Text()
+			if scanner.Scan() {
+				line = scanner.Text()
+			} else {
+				fmt.Println()
+				return
+			}
+			val, err := interpreter.EvalString(line)
+			if exit, ok := err.(*blisp.ExitError); ok {
+				os.Exit(exit.Code)
+			} else if err != nil {
+				fmt.Println(err)
+			} else if val != nil {
+				fmt.Println(val)
+			}
+		}
+	}
+	fmt.Println("Running [" + fileName + "]")
+	if len(args) > 1 {
+		flags := args[1:]
+		if blisp.StrArrIncludes(flags, "-b") {
+			benchmark = true
+		}
+	}
+	start := time.Now()
+	_, err := interpreter.EvalFile(fileName)
+	if exit, ok := err.(*blisp.ExitError); ok {
+		os.Exit(exit.Code)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if benchmark {
+		evalEnd := time.Since(start)
+		fmt.Println("\nFinished in", evalEnd)
+	}
+}
+
+func withExt(fileName string) string {
+	if !strings.Contains(fileName, ".blisp") {
+		fileName += ".blisp"
+	}
+	return fileName
+}
+
+// blisp check file... prints the problems found in each file without running
+// it, the exit status is 1 if any are errors and not only warnings
+func check(interpreter *blisp.Interpreter, files []string) int {
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: blisp check file...")
+		return 2
+	}
+	errors, warnings := 0, 0
+	for _, file := range files {
+		problems, err := interpreter.CheckFile(withExt(file))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			errors++
+			continue
+		}
+		for _, problem := range problems {
+			fmt.Println(problem)
+			if problem.Kind == blisp.Warning {
+				warnings++
+			} else {
+				errors++
+			}
+		}
+	}
+	if errors+warnings == 0 {
+		fmt.Println("No problems found")
+	} else {
+		fmt.Println("Found", errors, "error(s) and", warnings, "warning(s)")
+	}
+	if errors > 0 {
+		return 1
+	}
+	return 0
+}
+
+func removeFlag(args []string, flag string) []string {
+	res := []string{}
+	for _, v := range args {
+		if v != flag {
+			res = append(res, v)
+		}
+	}
+	return res
+}
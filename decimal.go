@@ -0,0 +1,97 @@
+package blisp
+
+import (
+	"math/big"
+	"strings"
+)
+
+// value of a Decimal, the number unscaled / 10^scale. Decimals keep the
+// digits they are written with, so 1.50d prints as 1.50
+type decimal struct {
+	unscaled *big.Int
+	scale    int
+}
+
+func (d decimal) String() string {
+	digits := new(big.Int).Abs(d.unscaled).String()
+	if len(digits) <= d.scale {
+		digits = strings.Repeat("0", d.scale-len(digits)+1) + digits
+	}
+	if d.scale > 0 {
+		digits = digits[:len(digits)-d.scale] + "." + digits[len(digits)-d.scale:]
+	}
+	if d.unscaled.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// decimal written as s, like 12.34d
+func parseDecimal(s string) (decimal, bool) {
+	if !strings.HasSuffix(s, "d") {
+		return decimal{}, false
+	}
+	whole, frac, _ := strings.Cut(s[:len(s)-1], ".")
+	digits := whole + frac
+	if digits == "" || strings.ContainsAny(digits[1:], "+-") || strings.ContainsAny(frac, "+-") {
+		return decimal{}, false
+	}
+	unscaled, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return decimal{}, false
+	}
+	return decimal{unscaled: unscaled, scale: len(frac)}, true
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+func (d decimal) rat() *big.Rat {
+	return new(big.Rat).SetFrac(d.unscaled, pow10(d.scale))
+}
+
+// d with the larger scale, which has to be at least its own
+func (d decimal) rescale(scale int) decimal {
+	if scale == d.scale {
+		return d
+	}
+	return decimal{unscaled: new(big.Int).Mul(d.unscaled, pow10(scale-d.scale)), scale: scale}
+}
+
+// d op e for op one of + - *, exact
+func (d decimal) arith(op string, e decimal) decimal {
+	if op == "*" {
+		return decimal{unscaled: new(big.Int).Mul(d.unscaled, e.unscaled), scale: d.scale + e.scale}
+	}
+	scale := d.scale
+	if e.scale > scale {
+		scale = e.scale
+	}
+	d, e = d.rescale(scale), e.rescale(scale)
+	if op == "+" {
+		return decimal{unscaled: new(big.Int).Add(d.unscaled, e.unscaled), scale: scale}
+	}
+	return decimal{unscaled: new(big.Int).Sub(d.unscaled, e.unscaled), scale: scale}
+}
+
+// d / e rounded half to even to the larger scale of the two, e is not zero
+func (d decimal) quo(e decimal) decimal {
+	scale := d.scale
+	if e.scale > scale {
+		scale = e.scale
+	}
+	// d / e * 10^scale is d.unscaled * 10^(scale - d.scale + e.scale) / e.unscaled
+	num := new(big.Int).Mul(d.unscaled, pow10(scale-d.scale+e.scale))
+	q, r := new(big.Int).QuoRem(num, e.unscaled, new(big.Int))
+	half := new(big.Int).Abs(r)
+	half.Lsh(half, 1)
+	if c := half.CmpAbs(e.unscaled); c > 0 || (c == 0 && q.Bit(0) == 1) {
+		if num.Sign()*e.unscaled.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return decimal{unscaled: q, scale: scale}
+}
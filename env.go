@@ -0,0 +1,91 @@
+package blisp
+
+// scope holding the variables and named functions defined in it, names not
+// found in a scope are looked up in its parent
+type env struct {
+	vars   map[string]*variable
+	funcs  map[string]function
+	parent *env
+}
+
+func newEnv(parent *env) *env {
+	return &env{parent: parent}
+}
+
+// scope that defines the variable name, nil if it is not defined
+func (e *env) lookupVar(name string) *variable {
+	for ; e != nil; e = e.parent {
+		if v, ok := e.vars[name]; ok {
+			return v
+		}
+	}
+	return nil
+}
+
+// named function visible from e
+func (e *env) lookupFunc(name string) (function, bool) {
+	for ; e != nil; e = e.parent {
+		if f, ok := e.funcs[name]; ok {
+			return f, true
+		}
+	}
+	return function{}, false
+}
+
+func (e *env) defineVar(v *variable) {
+	if e.vars == nil {
+		e.vars = make(map[string]*variable)
+	}
+	e.vars[v.name] = v
+}
+
+func (e *env) defineFunc(f function) {
+	if e.funcs == nil {
+		e.funcs = make(map[string]function)
+	}
+	e.funcs[f.name] = f
+}
+
+// innermost scope, names are looked up from here
+func CurrentEnv(ds *dataStore) *env {
+	return ds.frames[len(ds.frames)-1]
+}
+
+// scope at depth scopes, scopes in between are created so the ones at deeper
+// depths see the ones above them
+func GetEnv(ds *dataStore, scopes int) *env {
+	for len(ds.frames) < scopes {
+		ds.frames = append(ds.frames, newEnv(CurrentEnv(ds)))
+	}
+	if scopes < 1 {
+		return ds.frames[0]
+	}
+	return ds.frames[scopes-1]
+}
+
+// starts the scope a function body runs in at depth scopes, its parent is the
+// scope the function was made in instead of the one calling it
+func PushCallEnv(ds *dataStore, scopes int, f function) *env {
+	RemoveScopedVars(ds, scopes-1)
+	GetEnv(ds, scopes-1)
+	parent := f.env
+	if parent == nil {
+		parent = ds.frames[0]
+	}
+	e := newEnv(parent)
+	ds.frames = append(ds.frames, e)
+	return e
+}
+
+// drops the scopes deeper than keepScopes, the global scope is always kept
+func RemoveScopedVars(ds *dataStore, keepScopes int) {
+	if keepScopes < 1 {
+		keepScopes = 1
+	}
+	for i := keepScopes; i < len(ds.frames); i++ {
+		ds.frames[i] = nil
+	}
+	if keepScopes < len(ds.frames) {
+		ds.frames = ds.frames[:keepScopes]
+	}
+}
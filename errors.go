@@ -0,0 +1,166 @@
+package blisp
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+type ErrorKind int
+
+var errorKinds []string = []string{
+	"RuntimeError",
+	"TypeError",
+	"ArityError",
+	"NameError",
+	"IndexError",
+	"SyntaxError",
+	"IOError",
+	"MatchError",
+	"Warning",
+}
+
+const (
+	RuntimeError ErrorKind = iota
+	TypeError
+	ArityError
+	NameError
+	IndexError
+	SyntaxError
+	IOError
+	MatchError
+	// only used by the checker for code that is allowed but likely a mistake
+	Warning
+)
+
+func (k ErrorKind) String() string {
+	return errorKinds[k]
+}
+
+// BlispError is the error returned when evaluating blisp code fails.
+// Site is the name of the function that was being called when the error
+// happened, or empty if the error did not happen inside a call. File, Line
+// and Column locate the call (or the token for syntax errors) in the source,
+// Line is 0 when the location is unknown.
+type BlispError struct {
+	Kind    ErrorKind
+	Message string
+	Site    string
+	File    string
+	Line    int
+	Column  int
+	Source  string
+}
+
+// Error formats the error as "file:line:col: Kind in "site": message"
+// followed by the offending source line with a caret under the column
+func (e *BlispError) Error() string {
+	res := ""
+	if e.Line > 0 {
+		res += e.File + ":" + strconv.Itoa(e.Line) + ":" + strconv.Itoa(e.Column) + ": "
+	}
+	res += e.Kind.String()
+	msg := e.Message
+	if e.Site != "" {
+		res += " in \"" + e.Site + "\""
+		// messages of builtins start by naming them too, the site is only
+		// given once
+		if prefix := "Error in \"" + e.Site + "\""; strings.HasPrefix(msg, prefix) {
+			msg = strings.TrimLeft(msg[len(prefix):], ", ")
+		}
+	}
+	res += ": " + msg
+	if e.Line > 0 && e.Source != "" {
+		res += "\n    " + e.Source + "\n    " + caretPadding(e.Source, e.Column) + "^"
+	}
+	return res
+}
+
+// whitespace lining a caret up with col, keeping tabs so it matches the line
+func caretPadding(line string, col int) string {
+	padding := []rune{}
+	for i, c := range line {
+		if i >= col-1 {
+			break
+		}
+		if c == '\t' {
+			padding = append(padding, '\t')
+		} else {
+			padding = append(padding, ' ')
+		}
+	}
+	return string(padding)
+}
+
+func (e *BlispError) setPos(pos position) {
+	if pos.src == nil || e.Line > 0 {
+		return
+	}
+	e.File = pos.src.name
+	e.Line = pos.line
+	e.Column = pos.col
+	e.Source = pos.sourceLine()
+}
+
+// ExitError is returned when a script calls exit, the program running it
+// decides whether to end the process with Code
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return "exit status " + strconv.Itoa(e.Code)
+}
+
+// stops evaluation with an error, args are formatted like fmt.Sprint
+func throwError(kind ErrorKind, args ...any) {
+	panic(&BlispError{Kind: kind, Message: fmt.Sprint(args...)})
+}
+
+// same as throwError for errors that point at a known place in the source
+func throwErrorAt(kind ErrorKind, pos position, args ...any) {
+	err := &BlispError{Kind: kind, Message: fmt.Sprint(args...)}
+	err.setPos(pos)
+	panic(err)
+}
+
+// records the call site on errors raised while calling name at pos
+func setErrorSite(name string, pos position) {
+	if r := recover(); r != nil {
+		err := toBlispError(r)
+		if err.Site == "" {
+			err.Site = name
+		}
+		err.setPos(pos)
+		panic(err)
+	}
+}
+
+// stores a recovered evaluation failure in err, used as defer catchError(&err)
+func catchError(err *error) {
+	if r := recover(); r != nil {
+		*err = toError(r)
+	}
+}
+
+// error for a recovered panic that ends evaluation, a call to exit or a
+// BlispError
+func toError(r any) error {
+	if exit, ok := r.(*ExitError); ok {
+		return exit
+	}
+	return toBlispError(r)
+}
+
+// converts a recovered panic into a BlispError, go runtime errors like a bad
+// index are reported as RuntimeError, anything else is not ours to handle
+func toBlispError(r any) *BlispError {
+	switch err := r.(type) {
+	case *BlispError:
+		return err
+	case runtime.Error:
+		return &BlispError{Kind: RuntimeError, Message: err.Error()}
+	}
+	panic(r)
+}
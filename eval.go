@@ -0,0 +1,514 @@
+package blisp
+
+import (
+	"fmt"
+	"strings"
+)
+
+type DataType int
+
+var dataTypes []string = []string{
+	"Int",
+	"String",
+	"Float",
+	"Bool",
+	"List",
+	"Ident",
+	"Func",
+	"Nil",
+	"Body",
+	"Struct",
+	"BreakVals",
+	"ReturnVals",
+	"TailCall",
+	"Symbol",
+	"Map",
+	"Module",
+	"Pattern",
+	"Protocol",
+	"Enum",
+	"Variant",
+	"Rational",
+	"Decimal",
+	"Function",
+}
+
+const (
+	Int DataType = iota
+	String
+	Float
+	Bool
+	List
+	Ident
+	Func
+	Nil
+	Body
+	Struct
+	BreakVal    // dataType
+	ReturnVal   // dataType
+	TailCallVal // tailCall
+	Symbol      // string, an identifier in code used as data
+	Map         // *hashMap
+	Module      // *module
+	Pattern     // *pattern
+	Protocol    // *protocol
+	Enum        // *union
+	Variant     // variant
+	Rational    // *big.Rat, never a whole number
+	Decimal     // decimal
+)
+
+// types of values a program can have, the rest are only used while running
+var valueTypes = []DataType{Int, String, Float, Bool, List, Func, Nil, Struct, Symbol, Map, Module, Protocol, Enum, Variant, Rational, Decimal}
+
+// type named name in code, like Int in (Int n) or (w Int)
+func typeByName(name string) (DataType, bool) {
+	for _, t := range valueTypes {
+		if dataTypes[t] == name {
+			return t, true
+		}
+	}
+	return 0, false
+}
+
+type dataType struct {
+	dataType DataType
+	value    any
+}
+
+type variable struct {
+	name    string
+	isConst bool
+	data    dataType
+}
+
+type function struct {
+	name   string
+	body   []*node
+	params []param
+	// name of the &rest param, empty if the function has none
+	rest string
+	// type the value returned must have, nil if it is not annotated
+	returns *annotation
+	// set for the constructor of a record, which makes an instance from its
+	// args instead of running a body
+	rec *record
+	// set for the constructor of a tag with fields
+	tag    *tag
+	native func(*dataStore, []dataType) *[]dataType
+	// scope the function was made in, the body can see its variables even
+	// after it has ended
+	env *env
+}
+
+// param of a function, optional params get the value of def when no arg is
+// given for them, or nil if def is nil
+type param struct {
+	name string
+	// pattern the arg is destructured with instead of being bound to name
+	pattern  *pattern
+	optional bool
+	def      *node
+	// type the arg must have, nil if the param is not annotated
+	typ *annotation
+}
+
+// type given by an annotation, one of the types of values or the name of a
+// record or enum. Those are looked up when the function is made, values of
+// a record are Structs made by it and values of an enum are its Variants
+type annotation struct {
+	name string
+	t    DataType
+	rec  *record
+	enum *union
+}
+
+func newAnnotation(name string) *annotation {
+	t, _ := typeByName(name)
+	return &annotation{name: name, t: t}
+}
+
+// true if the annotation names a record or enum instead of a type of value
+func (a *annotation) named() bool {
+	_, ok := typeByName(a.name)
+	return !ok
+}
+
+func (a *annotation) includes(val dataType) bool {
+	switch {
+	case a.rec != nil:
+		return val.dataType == Struct && val.value.(structVal).rec == a.rec
+	case a.enum != nil:
+		return val.dataType == Variant && val.value.(variant).tag.of == a.enum
+	}
+	return val.dataType == a.t
+}
+
+func (a *annotation) String() string {
+	return "\"" + a.name + "\""
+}
+
+// call made from tail position, run by the function it is in after its body
+// has ended
+type tailCall struct {
+	name string
+	f    function
+	args []dataType
+	pos  position
+}
+
+type structAttr struct {
+	name string
+	attr *dataType
+}
+
+// value of a Struct
+type structVal struct {
+	attrs []structAttr
+	// record type the struct was made by, nil for struct literals
+	rec *record
+	// struct attributes are looked up in when the struct does not have them
+	proto *structVal
+	// protocols given to implements
+	protocols []*protocol
+}
+
+type dataStore struct {
+	// frames[i] is the scope at depth i+1, frames[0] holds the globals
+	frames   []*env
+	builtins map[string]*builtin
+	macros   map[string]function
+	// modules loaded by require by path, the files being evaluated with the
+	// innermost last and the module being loaded if any
+	modules map[string]*module
+	files   []string
+	module  *module
+	inFunc  bool
+	inLoop  bool
+	// number of function bodies being run, tail calls are only made inside one
+	funcDepth int
+	useVM     bool
+	// Ints that do not divide exactly give a Rational instead of a Float
+	exact bool
+	// methods being run by ., innermost last
+	methods []methodCall
+}
+
+func newDataStore() *dataStore {
+	ds := new(dataStore)
+	ds.frames = []*env{newEnv(nil)}
+	ds.builtins = make(map[string]*builtin)
+	ds.macros = make(map[string]function)
+	ds.modules = make(map[string]*module)
+	ds.files = []string{"<string>"}
+	ds.inFunc = false
+	ds.inLoop = false
+	ds.useVM = false
+	InitBuiltins(ds)
+	return ds
+}
+
+func StrArrIncludes(arr []string, val ...string) bool {
+	for _, v := range arr {
+		for _, check := range val {
+			if check == v {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func StrArrMap(arr []string, f func(string) string) []string {
+	res := arr
+	for i, v := range arr {
+		res[i] = f(v)
+	}
+	return res
+}
+
+func TokensToString(tokens []token) []string {
+	res := []string{}
+	for _, v := range tokens {
+		res = append(res, fmt.Sprint(v.value))
+	}
+	return res
+}
+
+func GetVariableFrom(name string, val dataType, isConst bool) variable {
+	var variable variable
+	variable.name = name
+	variable.data = val
+	variable.isConst = isConst
+	return variable
+}
+
+func GetStrSlice(str string) (string, int) {
+	res := []rune{}
+	for i, v := range str {
+		res = append(res, v)
+		if i > 0 {
+			if v == '"' && str[i-1] != '\\' {
+				return string(res), i
+			}
+		}
+	}
+	return "", -1
+}
+
+func QuoteToQuoteLiteral(str string) string {
+	res := str
+	for i := 0; i < len(res); i++ {
+		if res[i] == '"' {
+			if i > 0 {
+				if res[i-1] == '\\' {
+					continue
+				} else {
+					res = res[:i] + "\\" + res[i:]
+					i++
+				}
+			} else {
+				res = res[:i] + "\\" + res[i:]
+				i++
+			}
+		}
+	}
+	return res
+}
+
+func PrepQuotesString(str string) string {
+	temp := str
+	startLen := len(temp)
+	diff := startLen - len(strings.ReplaceAll(temp, "\"", ""))
+	res := str
+	quoteNum := 0
+	for i := 0; i < len(res); i++ {
+		if res[i] == '"' {
+			quoteNum++
+			if i > 0 {
+				if res[i-1] == '\\' {
+					if quoteNum == 1 || quoteNum == diff {
+						res = res[:i-1] + res[i:]
+					}
+					continue
+				}
+			}
+		}
+	}
+	return res
+}
+
+func GetDataTypeFromToken(t token) dataType {
+	var d dataType
+	switch t.tokenType {
+	case Identifier:
+		{
+			d.dataType = Ident
+			d.value = t.value.(string)
+		}
+	case StringToken:
+		{
+			d.dataType = String
+			str := t.value.(string)
+			d.value = str[1 : len(str)-1]
+		}
+	case BoolToken:
+		{
+			d.dataType = Bool
+			d.value = t.value.(bool)
+		}
+	case IntToken:
+		{
+			d.dataType = Int
+			d.value = t.value
+		}
+	case FloatToken:
+		{
+			d.dataType = Float
+			d.value = t.value.(float64)
+		}
+	case RationalToken:
+		{
+			d.dataType = Rational
+			d.value = t.value
+		}
+	case DecimalToken:
+		{
+			d.dataType = Decimal
+			d.value = t.value
+		}
+	case NilToken:
+		{
+			d.dataType = Nil
+			d.value = nil
+		}
+	default:
+		{
+			throwErrorAt(SyntaxError, t.pos, "Cannot infer datatype from: ", t.value)
+		}
+	}
+	return d
+}
+
+func EvalFunc(ds *dataStore, scopes int, info []dataType, pos position) (bool, *[]dataType) {
+	ds.inFunc = true
+	defer setErrorSite(calleeName(info, pos), pos)
+	if info[0].dataType == Func {
+		returnValue := CallInlineFunc(ds, scopes, "lambda", info[0].value.(function), info[1:])
+		return true, returnValue
+	} else if b := calledBuiltin(ds, info[0].value.(string)); b != nil {
+		return b.callsFunc(), b.call(ds, scopes, info[1:])
+	} else {
+		v := CallFunc(ds, scopes, info[0], info[1:])
+		return true, v
+	}
+
+	// f, ok := ds.builtins[info[0].value.(string)]; ok {
+	// v := f(ds, scopes, info[1:])
+	// isCustom := false
+	// if info[0].value.(string) == "." {
+	// 	isCustom = true
+	// }
+	// return isCustom, v
+}
+
+// name errors raised by a call to info are given, kept out of EvalFunc as
+// every call nested in another has it on the go stack
+func calleeName(info []dataType, pos position) string {
+	if len(info) == 0 {
+		throwErrorAt(SyntaxError, pos, "Expected function name")
+	}
+	switch info[0].dataType {
+	case Func:
+		return "lambda"
+	case Ident:
+		return info[0].value.(string)
+	}
+	throwErrorAt(TypeError, pos, "Cannot call type ", dataTypes[info[0].dataType])
+	return ""
+}
+
+// Eval evaluates code and returns the value of the last expr, any runtime
+// failure is returned as a *BlispError and a call to exit as an *ExitError
+func Eval(ds *dataStore, code []*node, scopes int) (res *[]dataType, err error) {
+	funcDepth := ds.funcDepth
+	defer func() {
+		if r := recover(); r != nil {
+			err = toError(r)
+			RemoveScopedVars(ds, scopes+1)
+			ds.inFunc = false
+			ds.inLoop = false
+			ds.funcDepth = funcDepth
+		}
+	}()
+	return evalBlock(ds, code, scopes), nil
+}
+
+// evaluates a block of expressions, returning the value of the last call
+// or the break/return value that ended the block early
+// blocks run on the bytecode vm when it is enabled, otherwise the tree is
+// walked directly
+func eval(ds *dataStore, code []*node, scopes int) *[]dataType {
+	if ds.useVM {
+		if len(code) == 0 {
+			return nil
+		}
+		return RunChunk(ds, GetChunk(ds, code), scopes)
+	}
+	return evalTree(ds, code, scopes)
+}
+
+func evalTree(ds *dataStore, code []*node, scopes int) *[]dataType {
+	var toReturn *[]dataType = nil
+	for _, n := range code {
+		if n.nodeType == CallNode {
+			valP, stop := evalCall(ds, n, scopes+1)
+			if stop {
+				return valP
+			}
+			toReturn = valP
+		} else if n.nodeType == BodyNode {
+			valP := eval(ds, n.children, scopes)
+			if valP != nil {
+				val := *valP
+				if len(val) > 0 && (val[0].dataType == BreakVal || val[0].dataType == ReturnVal) {
+					return valP
+				}
+			}
+			toReturn = valP
+		}
+	}
+	return toReturn
+}
+
+// evaluates the params of a call then calls it, stop is true when the result
+// is a break or return that has to end the enclosing block
+func evalCall(ds *dataStore, n *node, scopes int) (*[]dataType, bool) {
+	if exp := GetMacroExpansion(ds, n, scopes); exp != nil {
+		return evalExpansion(ds, exp, scopes)
+	}
+	info := make([]dataType, 0, len(n.children))
+	for _, child := range n.children {
+		if child.nodeType == CallNode {
+			valP, stop := evalCall(ds, child, scopes+1)
+			if stop {
+				return valP, true
+			}
+			if valP != nil {
+				info = append(info, *valP...)
+			}
+		} else {
+			info = append(info, evalValue(ds, child, scopes))
+		}
+	}
+	if n.tail && ds.funcDepth > 0 {
+		if call, ok := GetTailCall(ds, info, n.pos); ok {
+			return &[]dataType{call}, false
+		}
+	}
+	fromCustom, valP := EvalFunc(ds, scopes, info, n.pos)
+	RemoveScopedVars(ds, scopes)
+	if valP != nil {
+		val := *valP
+		if len(val) > 0 && (val[0].dataType == BreakVal || val[0].dataType == ReturnVal) {
+			if !fromCustom {
+				return valP, true
+			} else if val[0].dataType == ReturnVal {
+				valP = &[]dataType{val[0].value.(dataType)}
+			} else {
+				valP = nil
+			}
+		}
+	}
+	return valP, false
+}
+
+// value of a node passed as a param, idents are left for the function to
+// look up and bodies are left unevaluated
+func evalValue(ds *dataStore, n *node, scopes int) dataType {
+	switch n.nodeType {
+	case ListNode:
+		res := []dataType{}
+		for _, child := range n.children {
+			if child.nodeType == CallNode {
+				valP, _ := evalCall(ds, child, scopes+1)
+				if valP != nil {
+					res = append(res, *valP...)
+				}
+			} else {
+				res = append(res, evalValue(ds, child, scopes))
+			}
+		}
+		return dataType{dataType: List, value: res}
+	case BodyNode:
+		return dataType{dataType: Body, value: n.children}
+	case QuoteNode:
+		return nodeToData(n.children[0])
+	case QuasiquoteNode:
+		return evalQuasiquote(ds, n.children[0], scopes, 0)
+	}
+	if isStructLiteral(n) {
+		return structLiteral(ds, n)
+	}
+	return n.value
+}
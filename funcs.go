@@ -1,98 +1,13 @@
-package main
+package blisp
 
 import (
 	"fmt"
-	"log"
 	"math"
+	"math/big"
 	"os"
 	"strings"
-
-	"github.com/valyala/fastjson/fastfloat"
 )
 
-var reserved []string = []string{
-	"print",
-	"+",
-	"-",
-	"*",
-	"/",
-	"%",
-	"eval",
-	"var",
-	"set",
-	"free",
-	"type",
-	"get",
-	"true",
-	"false",
-	"loop",
-	"body",
-	"scan-line",
-	"if",
-	"eq",
-	"append",
-	"prepend",
-	"concat",
-	"exit",
-	"break",
-	"pop",
-	"remove",
-	"len",
-	"and",
-	"or",
-	"not",
-	"func",
-	"return",
-	"parse",
-	"<",
-	">",
-	"<=",
-	">=",
-	"read",
-	"write",
-	"substr",
-	"struct",
-	"shift",
-	".",
-	"while",
-	"++",
-	"--",
-	"+=",
-	"-=",
-	"require",
-	"from-char-code",
-	"char-code-from",
-	"split",
-	"is-letter",
-	"keys",
-	"values",
-	"floor",
-	"ceil",
-	"float",
-	"int",
-	"string",
-}
-
-func GetArr(tokens []token) (dataType, int) {
-	res := []dataType{}
-	index := 0
-	for i := 1; i < len(tokens); i++ {
-		if tokens[i].tokenType == OpenBracket {
-			arr, indx := GetArr(tokens[i:])
-			i += indx + 1
-			index += 2
-			res = append(res, arr)
-		} else if tokens[i].tokenType == CloseBracket {
-			return dataType{dataType: List, value: res}, index
-		} else {
-			res = append(res, GetDataTypeFromToken(tokens[i]))
-		}
-		index++
-	}
-	log.Fatal("Error, unable to find end of array.")
-	return dataType{dataType: Nil, value: nil}, 0
-}
-
 func GetArrStr(data dataType) dataType {
 	var d dataType
 	d.dataType = String
@@ -149,7 +64,10 @@ func PrintArr(data dataType) {
 
 func PrintStruct(ds *dataStore, val dataType) {
 	if val.dataType == Struct {
-		s := val.value.([]structAttr)
+		s := val.value.(structVal).attrs
+		if rec := val.value.(structVal).rec; rec != nil {
+			fmt.Print(rec.name + " ")
+		}
 		fmt.Println("{")
 		for _, attr := range s {
 			fmt.Print("\t" + fmt.Sprint(attr.name) + ": ")
@@ -157,7 +75,7 @@ func PrintStruct(ds *dataStore, val dataType) {
 		}
 		fmt.Println("}")
 	} else {
-		log.Fatal("Unable to PrintStruct for type ", dataTypes[val.dataType])
+		throwError(TypeError, "Unable to PrintStruct for type ", dataTypes[val.dataType])
 	}
 }
 
@@ -166,7 +84,7 @@ func Print(ds *dataStore, params ...dataType) {
 		if v.dataType == Ident {
 			v = GetDsValue(ds, v)
 			if v.dataType == Ident {
-				log.Fatal("Unknown value: ", v.value)
+				throwError(NameError, "Unknown value: ", v.value)
 			}
 		}
 		if v.dataType == List {
@@ -195,215 +113,74 @@ func GetStrValue(data dataType) string {
 }
 
 func Add(ds *dataStore, params ...dataType) dataType {
-	var res float64 = 0
+	res := dataType{dataType: Int, value: 0}
 	for _, v := range params {
-		if v.dataType == Ident {
-			v = GetDsValue(ds, v)
-		}
-		if v.dataType == Int {
-			res += float64(v.value.(int))
-		} else if v.dataType == Float {
-			res += v.value.(float64)
-		} else {
-			log.Fatal("Cannot + type ", dataTypes[v.dataType])
-		}
+		res = arith("+", res, GetDsValue(ds, v))
 	}
-	d := params[0]
-	if math.Floor(res) == res {
-		d.dataType = Int
-		d.value = int(res)
-	} else {
-		d.dataType = Float
-		d.value = res
-	}
-	return d
+	return numberResult(res)
 }
 
 func Sub(ds *dataStore, params ...dataType) dataType {
-	var res float64 = 0
-	firstVal := params[0]
-	if firstVal.dataType == Ident {
-		firstVal = GetDsValue(ds, firstVal)
-	}
-	if firstVal.dataType == Int {
-		res = float64(firstVal.value.(int))
-	} else if firstVal.dataType == Float {
-		res = firstVal.value.(float64)
-	} else {
-		log.Fatal("Cannot - type ", dataTypes[firstVal.dataType])
-	}
+	res := GetDsValue(ds, params[0])
 	if len(params) == 1 {
-		res *= -1
-	} else {
-		for _, v := range params[1:] {
-			if v.dataType == Ident {
-				v = GetDsValue(ds, v)
-			}
-			if v.dataType == Int {
-				res -= float64(v.value.(int))
-			} else if v.dataType == Float {
-				res -= v.value.(float64)
-			} else {
-				log.Fatal("Cannot - type ", dataTypes[v.dataType])
-			}
-		}
+		return numberResult(arith("-", dataType{dataType: Int, value: 0}, res))
 	}
-	d := firstVal
-	if math.Floor(res) == res {
-		d.dataType = Int
-		d.value = int(res)
-	} else {
-		d.dataType = Float
-		d.value = res
+	for _, v := range params[1:] {
+		res = arith("-", res, GetDsValue(ds, v))
 	}
-	return d
+	return numberResult(res)
 }
 
 func Mult(ds *dataStore, params ...dataType) dataType {
-	var res float64 = 0
-	firstVal := params[0]
-	if firstVal.dataType == Ident {
-		firstVal = GetDsValue(ds, firstVal)
-	}
-	if firstVal.dataType == Int {
-		res = float64(firstVal.value.(int))
-	} else if firstVal.dataType == Float {
-		res = firstVal.value.(float64)
-	} else {
-		log.Fatal("Cannot * type ", dataTypes[firstVal.dataType])
-	}
-	for _, v := range params[1:] {
-		if v.dataType == Ident {
-			v = GetDsValue(ds, v)
-		}
-		if v.dataType == Int {
-			res *= float64(v.value.(int))
-		} else if v.dataType == Float {
-			res *= v.value.(float64)
-		} else {
-			log.Fatal("Cannot * type ", dataTypes[v.dataType])
-		}
-	}
-	d := params[0]
-	if math.Floor(res) == res {
-		d.dataType = Int
-		d.value = int(res)
-	} else {
-		d.dataType = Float
-		d.value = res
+	res := dataType{dataType: Int, value: 1}
+	for _, v := range params {
+		res = arith("*", res, GetDsValue(ds, v))
 	}
-	return d
+	return numberResult(res)
 }
 
 func Divide(ds *dataStore, params ...dataType) dataType {
-	var res float64 = 0
-	firstVal := params[0]
-	if firstVal.dataType == Ident {
-		firstVal = GetDsValue(ds, firstVal)
-	}
-	if firstVal.dataType == Int {
-		res = float64(firstVal.value.(int))
-	} else if firstVal.dataType == Float {
-		res = firstVal.value.(float64)
-	} else {
-		log.Fatal("Cannot / type ", dataTypes[firstVal.dataType])
+	res := GetDsValue(ds, params[0])
+	if !isNumber(res) {
+		throwError(TypeError, "Cannot / type ", dataTypes[res.dataType])
 	}
 	for _, v := range params[1:] {
-		if v.dataType == Ident {
-			v = GetDsValue(ds, v)
-		}
-		if v.dataType == Int {
-			res /= float64(v.value.(int))
-		} else if v.dataType == Float {
-			res /= v.value.(float64)
-		} else {
-			log.Fatal("Cannot / type ", dataTypes[v.dataType])
-		}
+		res = quotient(ds.exact, res, GetDsValue(ds, v))
 	}
-	d := params[0]
-	if math.Floor(res) == res {
-		d.dataType = Int
-		d.value = int(res)
-	} else {
-		d.dataType = Float
-		d.value = res
-	}
-	return d
+	return numberResult(res)
 }
 
 func Exp(ds *dataStore, base dataType, exp dataType) dataType {
-	var num1 float64 = 0
-	if base.dataType == Ident {
-		base = GetDsValue(ds, base)
-	}
-	if base.dataType == Float {
-		num1 = base.value.(float64)
-	} else if base.dataType == Int {
-		num1 = float64(base.value.(int))
-	} else {
-		log.Fatal("Cannot ^ type ", dataTypes[base.dataType])
-	}
-	var num2 float64 = 0
-	if exp.dataType == Ident {
-		exp = GetDsValue(ds, exp)
-	}
-	if exp.dataType == Float {
-		num2 = exp.value.(float64)
-	} else if exp.dataType == Int {
-		num2 = float64(exp.value.(int))
-	} else {
-		log.Fatal("Cannot ^ type ", dataTypes[exp.dataType])
-	}
-	res := math.Pow(num1, num2)
-	d := base
-	if math.Floor(res) == res {
-		d.dataType = Int
-		d.value = int(res)
-	} else {
-		d.dataType = Float
-		d.value = res
-	}
-	return d
+	return power(ds.exact, GetDsValue(ds, base), GetDsValue(ds, exp))
 }
 
 func Mod(ds *dataStore, num1 dataType, num2 dataType) dataType {
-	val1 := 0
-	if num1.dataType == Ident {
-		num1 = GetDsValue(ds, num1)
-	}
-	if num1.dataType == Int {
-		val1 = num1.value.(int)
-	} else {
-		log.Fatal("Cannot % type ", dataTypes[num1.dataType])
+	num1 = GetDsValue(ds, num1)
+	num2 = GetDsValue(ds, num2)
+	for _, v := range []dataType{num1, num2} {
+		if v.dataType != Int {
+			throwError(TypeError, "Cannot % type ", dataTypes[v.dataType])
+		}
 	}
-	val2 := 0
-	if num2.dataType == Ident {
-		num2 = GetDsValue(ds, num2)
+	if bigOf(num2).Sign() == 0 {
+		throwError(RuntimeError, "Error in \"%\", division by zero")
 	}
-	if num2.dataType == Int {
-		val2 = num2.value.(int)
-	} else {
-		log.Fatal("Cannot % type", dataTypes[num2.dataType])
+	val1, ok1 := num1.value.(int)
+	val2, ok2 := num2.value.(int)
+	if ok1 && ok2 {
+		return dataType{dataType: Int, value: val1 % val2}
 	}
-	d := num1
-	d.dataType = Int
-	d.value = val1 % val2
-	return d
+	return intValue(new(big.Int).Rem(bigOf(num1), bigOf(num2)))
 }
 
 func MakeVar(ds *dataStore, scopes int, name string, data dataType, isConst bool) {
-	if scopes < len(ds.scopedVars) && StrArrIncludes(ds.scopedVars[scopes], name) {
-		log.Fatal("Variable already initialized: ", name)
-		return
-	}
-
-	if StrArrIncludes(reserved, name) {
-		log.Fatal("Variable name \"", name, "\" is reserved")
+	if IsReserved(ds, name) {
+		throwError(NameError, "Variable name \"", name, "\" is reserved")
 		return
 	}
 
 	if len(name) == 0 {
-		log.Fatal("Variable must have name")
+		throwError(NameError, "Variable must have name")
 		return
 	}
 
@@ -411,66 +188,47 @@ func MakeVar(ds *dataStore, scopes int, name string, data dataType, isConst bool
 		return
 	}
 
-	temp := ds.vars[name]
-	if len(temp) > 0 && temp[len(temp)-1].isConst {
-		log.Fatal("Variable is constant, unable to redefine value")
+	e := GetEnv(ds, scopes)
+	if v, ok := e.vars[name]; ok && v.isConst {
+		throwError(NameError, "Variable is constant, unable to redefine value")
 	}
 
-	if data.dataType == List {
-		ds.vars[name] = append(ds.vars[name], GetVariableFrom(name, data, isConst))
-	} else if data.dataType == Ident {
-		val := GetDsValue(ds, data)
-		ds.vars[name] = append(ds.vars[name], GetVariableFrom(name, val, isConst))
-	} else {
-		ds.vars[name] = append(ds.vars[name], GetVariableFrom(name, data, isConst))
-	}
-	for len(ds.scopedVars) < scopes {
-		ds.scopedVars = append(ds.scopedVars, []string{})
-	}
-	for len(ds.scopedRedef) < scopes {
-		ds.scopedRedef = append(ds.scopedRedef, []string{})
-	}
-	if _, ok := ds.vars[name]; ok {
-		ds.scopedRedef[scopes-1] = append(ds.scopedRedef[scopes-1], name)
-	} else {
-		ds.scopedVars[scopes-1] = append(ds.scopedVars[scopes-1], name)
+	if data.dataType == Ident {
+		data = GetDsValue(ds, data)
 	}
+	v := GetVariableFrom(name, data, isConst)
+	e.defineVar(&v)
 }
 
+// assigns to the nearest variable called name
 func SetVar(ds *dataStore, name string, data dataType) {
 	if name == "_" {
 		return
 	}
-	if v, ok := ds.vars[name]; !ok {
-		log.Fatal("Variable not initialized: ", name)
+	v := CurrentEnv(ds).lookupVar(name)
+	if v == nil {
+		throwError(NameError, "Variable not initialized: ", name)
 		return
-	} else {
-		if v[len(v)-1].isConst {
-			log.Fatal("Variable is constant, unable to set value")
-		}
+	}
+	if v.isConst {
+		throwError(NameError, "Variable is constant, unable to set value")
 	}
 	if data.dataType == Ident {
-		val := GetDsValue(ds, data)
-		ds.vars[name][len(ds.vars[name])-1] = GetVariableFrom(name, val, false)
-	} else {
-		ds.vars[name][len(ds.vars[name])-1] = GetVariableFrom(name, data, false)
+		data = GetDsValue(ds, data)
 	}
+	v.data = data
 }
 
+// removes the nearest variable called name, an outer variable it was
+// shadowing becomes visible again
 func FreeVar(ds *dataStore, name string) {
-	if _, ok := ds.vars[name]; !ok {
-		log.Fatal("Unable to free, variable not initialized: ", name)
-		return
-	}
-	delete(ds.vars, name)
-}
-
-func FreeFunc(ds *dataStore, name string) {
-	if _, ok := ds.funcs[name]; !ok {
-		log.Fatal("Unable to free, variable not initialized: " + name)
-		return
+	for e := CurrentEnv(ds); e != nil; e = e.parent {
+		if _, ok := e.vars[name]; ok {
+			delete(e.vars, name)
+			return
+		}
 	}
-	delete(ds.funcs, name)
+	throwError(NameError, "Unable to free, variable not initialized: ", name)
 }
 
 // gets value of token from ds
@@ -479,25 +237,47 @@ func FreeFunc(ds *dataStore, name string) {
 // ---> returns the input
 func GetDsValue(ds *dataStore, val dataType) dataType {
 	if val.dataType == Ident {
-		if v, ok := ds.vars[val.value.(string)]; ok {
-			if len(v) == 0 {
-				return val
-			}
-			return v[len(v)-1].data
-		} else if v, ok := ds.funcs[val.value.(string)]; ok {
-			f := v[len(v)-1]
+		e := CurrentEnv(ds)
+		if v := e.lookupVar(val.value.(string)); v != nil {
+			return v.data
+		} else if f, ok := e.lookupFunc(val.value.(string)); ok {
 			return dataType{dataType: Func, value: f}
+		} else if v, ok := lookupQualified(e, val.value.(string)); ok {
+			return v
 		}
 	}
 	return val
 }
 
+// index of an item of a sequence of length n given to name, an index that is
+// out of range or too large for an int raises an IndexError
+func itemIndex(name string, index dataType, n int) int {
+	i, ok := index.value.(int)
+	if !ok || i < 0 || i >= n {
+		throwError(IndexError, "Error in \"", name, "\", index ", GetStrValue(index), " out of bounds for length ", n)
+	}
+	return i
+}
+
+// position between the items of a sequence of length n given to name, from
+// lo up to n
+func slicePos(name string, pos dataType, lo int, n int) int {
+	i, ok := pos.value.(int)
+	if !ok || i < lo || i > n {
+		throwError(IndexError, "Error in \"", name, "\", index ", GetStrValue(pos), " out of bounds ", lo, " to ", n)
+	}
+	return i
+}
+
 func GetFromValue(ds *dataStore, val dataType, index dataType) dataType {
 	if val.dataType == Ident {
 		val = GetDsValue(ds, val)
 	}
+	if val.dataType == Variant {
+		return variantField(val, index)
+	}
 	if val.dataType != String && val.dataType != List && val.dataType != Struct {
-		log.Fatal("Error in \"get\", expected \"String\", \"List\", or \"Struct\" found ", dataTypes[val.dataType])
+		throwError(TypeError, "Error in \"get\", expected \"String\", \"List\", \"Struct\" or \"Variant\" found ", dataTypes[val.dataType])
 	}
 
 	if val.dataType != Struct {
@@ -505,11 +285,11 @@ func GetFromValue(ds *dataStore, val dataType, index dataType) dataType {
 			index = GetDsValue(ds, index)
 		}
 		if index.dataType != Int {
-			log.Fatal("Error in \"get\", expected \"Int\" found ", dataTypes[index.dataType])
+			throwError(TypeError, "Error in \"get\", expected \"Int\" found ", dataTypes[index.dataType])
 		}
 	} else {
 		if index.dataType != Ident && index.dataType != Int && index.dataType != Float && index.dataType != String && index.dataType != Bool {
-			log.Fatal("Unable to index \"Struct\" with type ", dataTypes[index.dataType])
+			throwError(TypeError, "Unable to index \"Struct\" with type ", dataTypes[index.dataType])
 		}
 	}
 
@@ -517,13 +297,20 @@ func GetFromValue(ds *dataStore, val dataType, index dataType) dataType {
 		parts := strings.Split(val.value.(string), "")
 		var d dataType
 		d.dataType = String
-		d.value = parts[index.value.(int)]
+		d.value = parts[itemIndex("get", index, len(parts))]
 		return d
 	} else if val.dataType == List {
 		parts := val.value.([]dataType)
-		return parts[index.value.(int)]
+		return parts[itemIndex("get", index, len(parts))]
 	} else if val.dataType == Struct {
-		parts := val.value.([]structAttr)
+		parts := val.value.(structVal).attrs
+		// an attribute name wins over a variable of the same name, closures
+		// can see variables named like the attributes of their struct
+		if index.dataType == Ident {
+			if attr, _, _ := lookupAttr(val.value.(structVal), index.value.(string)); attr != nil {
+				return *attr
+			}
+		}
 		for i := 0; i < len(parts); i++ {
 			if index.dataType != Ident && index.dataType != Int && index.dataType != Float && index.dataType != String && index.dataType != Bool {
 				continue
@@ -540,25 +327,24 @@ func GetFromValue(ds *dataStore, val dataType, index dataType) dataType {
 	return dataType{dataType: Nil, value: nil}
 }
 
+// starts a loop iteration with a new scope at depth scopes+1 for its
+// variables, so closures made in the body keep the values of their iteration
+func newIteration(ds *dataStore, scopes int) {
+	RemoveScopedVars(ds, scopes)
+}
+
 func LoopListIterator(ds *dataStore, scopes int, list dataType, iteratorName dataType, body dataType) *[]dataType {
 	if list.dataType == Ident {
 		list = GetDsValue(ds, list)
 	}
 	if list.dataType != List {
-		log.Fatal("Error in \"loop\" expected \"List\" found ", dataTypes[list.dataType])
+		throwError(TypeError, "Error in \"loop\" expected \"List\" found ", dataTypes[list.dataType])
 	}
-	if iteratorName.dataType != Ident {
-		log.Fatal("Error in \"loop\" expected \"Ident\" found ", dataTypes[list.dataType])
-	}
-	made := false
+	checkIterator(iteratorName)
 	for _, v := range list.value.([]dataType) {
-		if !made {
-			MakeVar(ds, scopes+1, iteratorName.value.(string), v, false)
-		} else {
-			SetVar(ds, iteratorName.value.(string), v)
-		}
-		made = true
-		valP := Eval(ds, body.value.([]token), scopes)
+		newIteration(ds, scopes)
+		MakeVars(ds, scopes+1, iteratorName, v, false)
+		valP := eval(ds, GetBody("loop", body), scopes)
 		if valP != nil {
 			val := *valP
 			if len(val) > 0 && (val[0].dataType == BreakVal || val[0].dataType == ReturnVal) {
@@ -577,17 +363,12 @@ func LoopListIndexIterator(ds *dataStore, scopes int, list dataType, indexIterat
 	if arr.dataType == Ident {
 		arr = GetDsValue(ds, list)
 	}
-	made := false
+	checkIterator(iteratorName)
 	for i, v := range arr.value.([]dataType) {
-		if !made {
-			MakeVar(ds, scopes+1, iteratorName.value.(string), v, false)
-			MakeVar(ds, scopes+1, indexIterator.value.(string), dataType{dataType: Int, value: i}, false)
-		} else {
-			SetVar(ds, iteratorName.value.(string), v)
-			SetVar(ds, indexIterator.value.(string), dataType{dataType: Int, value: i})
-		}
-		made = true
-		valP := Eval(ds, body.value.([]token), scopes)
+		newIteration(ds, scopes)
+		MakeVars(ds, scopes+1, iteratorName, v, false)
+		MakeVar(ds, scopes+1, indexIterator.value.(string), dataType{dataType: Int, value: i}, false)
+		valP := eval(ds, GetBody("loop", body), scopes)
 		if valP != nil {
 			val := *valP
 			if len(val) > 0 && (val[0].dataType == BreakVal || val[0].dataType == ReturnVal) {
@@ -607,22 +388,17 @@ func LoopTo(ds *dataStore, scopes int, max dataType, indexIterator dataType, bod
 	}
 	var maxNum int
 	if max.dataType == Int {
-		maxNum = max.value.(int)
+		maxNum = intOf("loop", max)
 	} else {
-		log.Fatal("Error in \"loop\", expected \"Int\" found ", dataTypes[max.dataType])
+		throwError(TypeError, "Error in \"loop\", expected \"Int\" found ", dataTypes[max.dataType])
 	}
 	if indexIterator.dataType != Ident {
-		log.Fatal("Error in \"loop\" expected \"Ident\" found ", dataTypes[indexIterator.dataType])
+		throwError(TypeError, "Error in \"loop\" expected \"Ident\" found ", dataTypes[indexIterator.dataType])
 	}
-	made := false
 	for i := 0; i < maxNum; i++ {
-		if !made {
-			MakeVar(ds, scopes+1, indexIterator.value.(string), dataType{dataType: Int, value: i}, false)
-		} else {
-			SetVar(ds, indexIterator.value.(string), dataType{dataType: Int, value: i})
-		}
-		made = true
-		valP := Eval(ds, body.value.([]token), scopes)
+		newIteration(ds, scopes)
+		MakeVar(ds, scopes+1, indexIterator.value.(string), dataType{dataType: Int, value: i}, false)
+		valP := eval(ds, GetBody("loop", body), scopes)
 		if valP != nil {
 			val := *valP
 			if len(val) > 0 && (val[0].dataType == BreakVal || val[0].dataType == ReturnVal) {
@@ -644,17 +420,16 @@ func LoopFromTo(ds *dataStore, scopes int, start dataType, max dataType, indexIt
 		max = GetDsValue(ds, max)
 	}
 	if start.dataType != Int {
-		log.Fatal("Error in \"loop\", expected \"Int\" found ", dataTypes[start.dataType])
+		throwError(TypeError, "Error in \"loop\", expected \"Int\" found ", dataTypes[start.dataType])
 	}
 	if max.dataType != Int {
-		log.Fatal("Error in \"loop\", expected \"Int\" found ", dataTypes[max.dataType])
+		throwError(TypeError, "Error in \"loop\", expected \"Int\" found ", dataTypes[max.dataType])
 	}
 	if indexIterator.dataType != Ident {
-		log.Fatal("Error in \"loop\" expected \"Ident\" found ", dataTypes[indexIterator.dataType])
+		throwError(TypeError, "Error in \"loop\" expected \"Ident\" found ", dataTypes[indexIterator.dataType])
 	}
-	startNum := start.value.(int)
-	maxNum := max.value.(int)
-	made := false
+	startNum := intOf("loop", start)
+	maxNum := intOf("loop", max)
 	i := startNum
 	next := func() {
 		if startNum <= maxNum {
@@ -671,13 +446,9 @@ func LoopFromTo(ds *dataStore, scopes int, start dataType, max dataType, indexIt
 		}
 	}
 	for ; comp(); next() {
-		if !made {
-			MakeVar(ds, scopes+1, indexIterator.value.(string), dataType{dataType: Int, value: i}, false)
-		} else {
-			SetVar(ds, indexIterator.value.(string), dataType{dataType: Int, value: i})
-		}
-		made = true
-		valP := Eval(ds, body.value.([]token), scopes)
+		newIteration(ds, scopes)
+		MakeVar(ds, scopes+1, indexIterator.value.(string), dataType{dataType: Int, value: i}, false)
+		valP := eval(ds, GetBody("loop", body), scopes)
 		if valP != nil {
 			val := *valP
 			if len(val) > 0 && (val[0].dataType == BreakVal || val[0].dataType == ReturnVal) {
@@ -706,8 +477,11 @@ func CompareLists(ds *dataStore, val1 dataType, val2 dataType) bool {
 }
 
 func CompareStructs(ds *dataStore, val1 dataType, val2 dataType) bool {
-	s1 := val1.value.([]structAttr)
-	s2 := val1.value.([]structAttr)
+	if val1.value.(structVal).rec != val2.value.(structVal).rec {
+		return false
+	}
+	s1 := val1.value.(structVal).attrs
+	s2 := val1.value.(structVal).attrs
 	if len(s1) != len(s2) {
 		return false
 	}
@@ -739,10 +513,10 @@ func Eq(ds *dataStore, params ...dataType) bool {
 			val2 = GetDsValue(ds, val2)
 		}
 		if val1.dataType == Ident {
-			log.Fatal("Cannot compare unknown value ", val1.value)
+			throwError(NameError, "Cannot compare unknown value ", val1.value)
 		}
 		if val2.dataType == Ident {
-			log.Fatal("Cannot compare unknown value ", val2.value)
+			throwError(NameError, "Cannot compare unknown value ", val2.value)
 		}
 		if val1.dataType == List {
 			if val2.dataType == List {
@@ -753,7 +527,7 @@ func Eq(ds *dataStore, params ...dataType) bool {
 				return false
 			}
 		} else if val2.dataType == List {
-			log.Fatal("Cannot compare types \"List\" and ", dataTypes[val1.dataType])
+			throwError(TypeError, "Cannot compare types \"List\" and ", dataTypes[val1.dataType])
 		} else if val1.dataType == Struct {
 			if val2.dataType == Struct {
 				if !CompareStructs(ds, val1, val2) {
@@ -764,6 +538,22 @@ func Eq(ds *dataStore, params ...dataType) bool {
 			}
 		} else if val2.dataType == Struct {
 			return false
+		} else if val1.dataType == Variant || val2.dataType == Variant {
+			if val1.dataType != val2.dataType || !CompareVariants(ds, val1, val2) {
+				return false
+			}
+		} else if val1.dataType == Map || val2.dataType == Map {
+			if val1.dataType != val2.dataType || !CompareMaps(ds, val1, val2) {
+				return false
+			}
+		} else if (val1.dataType == Symbol) != (val2.dataType == Symbol) {
+			return false
+		} else if isNumber(val1) && isNumber(val2) {
+			// numbers of different types are equal if their values are, NaN
+			// is not equal to anything
+			if comp, ok := compareNumbers(val1, val2); !ok || comp != 0 {
+				return false
+			}
 		} else if val1.value != val2.value {
 			return false
 		}
@@ -771,6 +561,14 @@ func Eq(ds *dataStore, params ...dataType) bool {
 	return true
 }
 
+// nodes of a (body ...) param to the function name
+func GetBody(name string, val dataType) []*node {
+	if val.dataType != Body {
+		throwError(TypeError, "Error in \"", name, "\", expected \"Body\" found ", dataTypes[val.dataType])
+	}
+	return val.value.([]*node)
+}
+
 func If(ds *dataStore, scopes int, params ...dataType) *[]dataType {
 	var toReturn *[]dataType = nil
 	info := params[0]
@@ -780,16 +578,76 @@ func If(ds *dataStore, scopes int, params ...dataType) *[]dataType {
 	if info.dataType == Bool {
 		val := info.value.(bool)
 		if val {
-			toReturn = Eval(ds, params[1].value.([]token), scopes)
+			toReturn = evalBlock(ds, GetBody("if", params[1]), scopes)
 		} else if len(params) == 3 {
-			toReturn = Eval(ds, params[2].value.([]token), scopes)
+			toReturn = evalBlock(ds, GetBody("if", params[2]), scopes)
 		}
 	} else {
-		log.Fatal("Error in \"if\", expected type: \"Bool\" found ", dataTypes[info.dataType])
+		throwError(TypeError, "Error in \"if\", expected type: \"Bool\" found ", dataTypes[info.dataType])
 	}
 	return toReturn
 }
 
+// runs a block and returns the value of its last expr, unlike eval the last
+// expr can be a value like "a" or x instead of a call
+func evalBlock(ds *dataStore, code []*node, scopes int) *[]dataType {
+	res := eval(ds, code, scopes)
+	if len(code) == 0 {
+		return res
+	}
+	last := code[len(code)-1]
+	if last.nodeType == CallNode || last.nodeType == BodyNode {
+		return res
+	}
+	if res != nil && len(*res) > 0 && ((*res)[0].dataType == BreakVal || (*res)[0].dataType == ReturnVal) {
+		return res
+	}
+	return &[]dataType{GetDsValue(ds, evalValue(ds, last, scopes))}
+}
+
+// value of a condition, name is the form it is in used in errors
+func GetCondition(ds *dataStore, name string, n *node, scopes int) bool {
+	val := evalNodeValue(ds, n, scopes)
+	if val.dataType != Bool {
+		throwErrorAt(TypeError, n.pos, "Error in \"", name, "\", expected type: \"Bool\" found ", dataTypes[val.dataType])
+	}
+	return val.value.(bool)
+}
+
+// (cond (test exprs...)... (else exprs...)) runs the exprs of the first clause
+// whose test is true and returns the value of the last one
+func Cond(ds *dataStore, scopes int, params []dataType) *[]dataType {
+	for i, clause := range params {
+		nodes := GetBody("cond", clause)
+		if len(nodes) == 0 {
+			throwError(SyntaxError, "Error in \"cond\", expected (test exprs...) found ()")
+		}
+		test := nodes[0]
+		if test.nodeType == IdentNode && test.value.value == "else" {
+			if i != len(params)-1 {
+				throwErrorAt(SyntaxError, test.pos, "Error in \"cond\", else must be the last clause")
+			}
+		} else if !GetCondition(ds, "cond", test, scopes) {
+			continue
+		}
+		return evalBlock(ds, nodes[1:], scopes)
+	}
+	return nil
+}
+
+// (when test exprs...) runs the exprs if test is true, unless runs them if it
+// is false
+func When(ds *dataStore, scopes int, name string, params []dataType) *[]dataType {
+	cond := GetDsValue(ds, params[0])
+	if cond.dataType != Bool {
+		throwError(TypeError, "Error in \"", name, "\", expected type: \"Bool\" found ", dataTypes[cond.dataType])
+	}
+	if cond.value.(bool) == (name == "when") {
+		return evalBlock(ds, GetBody(name, params[1]), scopes)
+	}
+	return nil
+}
+
 func AppendToList(ds *dataStore, list []dataType, data ...dataType) []dataType {
 	for _, v := range data {
 		if v.dataType == Ident {
@@ -833,7 +691,7 @@ func ListFunc(ds *dataStore, f func(ds *dataStore, list []dataType, val ...dataT
 		}
 		return list
 	} else {
-		log.Fatal("Error mutating list, expected type \"List\" found ", dataTypes[list.dataType])
+		throwError(TypeError, "Error mutating list, expected type \"List\" found ", dataTypes[list.dataType])
 	}
 	return dataType{dataType: Nil, value: nil}
 }
@@ -863,7 +721,7 @@ func Pop(ds *dataStore, list dataType) dataType {
 		list = GetDsValue(ds, list)
 	}
 	if list.dataType != List {
-		log.Fatal("Error in \"pop\" expected \"List\" found ", dataTypes[list.dataType])
+		throwError(TypeError, "Error in \"pop\" expected \"List\" found ", dataTypes[list.dataType])
 	}
 	items := list.value.([]dataType)
 	if len(items) > 0 {
@@ -888,18 +746,15 @@ func Remove(ds *dataStore, val dataType, index dataType) dataType {
 		val = GetDsValue(ds, val)
 	}
 	if val.dataType == List {
-		listIndex := 0
-		var listIndexData dataType
 		if index.dataType == Ident {
-			listIndexData = GetDsValue(ds, index)
+			index = GetDsValue(ds, index)
 		}
-		if listIndexData.dataType == Int {
-			listIndex = listIndexData.value.(int)
-		} else {
-			log.Fatal("Error in \"remove\" expected \"Int\" found ", dataTypes[val.dataType])
+		if index.dataType != Int {
+			throwError(TypeError, "Error in \"remove\" expected \"Int\" found ", dataTypes[index.dataType])
 		}
 		items := val.value.([]dataType)
 		if len(items) > 0 {
+			listIndex := itemIndex("remove", index, len(items))
 			item := items[listIndex]
 			items = append(items[:listIndex], items[listIndex+1:]...)
 			val.value = items
@@ -911,16 +766,20 @@ func Remove(ds *dataStore, val dataType, index dataType) dataType {
 			return dataType{dataType: Nil, value: nil}
 		}
 	} else if val.dataType == Struct {
-		strct := val.value.([]structAttr)
+		strct := val.value.(structVal).attrs
+		if rec := val.value.(structVal).rec; rec != nil {
+			throwError(TypeError, "Error in \"remove\", can not remove a field of ", rec.name)
+		}
 		if index.dataType != Ident {
-			log.Fatal("Error in \"remove\" expected \"Ident\" found ", dataTypes[val.dataType])
+			throwError(TypeError, "Error in \"remove\" expected \"Ident\" found ", dataTypes[val.dataType])
 		}
 		item := dataType{dataType: Nil, value: nil}
 		for i := 0; i < len(strct); i++ {
 			if strct[i].name == index.value.(string) {
 				item = *strct[i].attr
-				strct = append(strct[:i], strct[i+1:]...)
-				val.value = strct
+				s := val.value.(structVal)
+				s.attrs = append(strct[:i], strct[i+1:]...)
+				val.value = s
 				if isIdent {
 					SetVar(ds, name, val)
 				}
@@ -929,7 +788,7 @@ func Remove(ds *dataStore, val dataType, index dataType) dataType {
 		}
 		return item
 	} else {
-		log.Fatal("Error in \"remove\" expected \"List\" or \"Struct\" found ", dataTypes[val.dataType])
+		throwError(TypeError, "Error in \"remove\" expected \"List\" or \"Struct\" found ", dataTypes[val.dataType])
 	}
 	return dataType{value: nil, dataType: Nil}
 }
@@ -944,7 +803,10 @@ func Len(ds *dataStore, list dataType) int {
 	if list.dataType == String {
 		return len(list.value.(string))
 	}
-	log.Fatal("Error in \"len\", unable to get length of type ", dataTypes[list.dataType])
+	if list.dataType == Map {
+		return list.value.(*hashMap).Len()
+	}
+	throwError(TypeError, "Error in \"len\", unable to get length of type ", dataTypes[list.dataType])
 	return 0
 }
 
@@ -954,7 +816,7 @@ func And(ds *dataStore, params ...dataType) bool {
 			v = GetDsValue(ds, v)
 		}
 		if v.dataType != Bool {
-			log.Fatal("Error in \"and\", expected \"Bool\" found ", dataTypes[v.dataType])
+			throwError(TypeError, "Error in \"and\", expected \"Bool\" found ", dataTypes[v.dataType])
 		}
 		if !v.value.(bool) {
 			return false
@@ -980,18 +842,21 @@ func SetValue(ds *dataStore, val dataType, index dataType, value dataType) {
 		val = GetDsValue(ds, val)
 	}
 	if val.dataType != Struct && val.dataType != List {
-		log.Fatal("Error in \"set\", expected \"Struct\" or \"List\" found ", dataTypes[val.dataType])
+		throwError(TypeError, "Error in \"set\", expected \"Struct\" or \"List\" found ", dataTypes[val.dataType])
 	}
 
 	if val.dataType == Struct {
 		if index.dataType != Ident {
-			log.Fatal("Error in \"set\", expected \"Ident\" found ", dataTypes[index.dataType])
+			throwError(TypeError, "Error in \"set\", expected \"Ident\" found ", dataTypes[index.dataType])
 		}
 
-		s := val.value.([]structAttr)
-		for i := 0; i < len(s); i++ {
-			if s[i].name == index.value.(string) {
-				s[i].attr = &value
+		s := val.value.(structVal)
+		if s.rec != nil {
+			s.rec.checkField(ds, index.value.(string), value)
+		}
+		for i := 0; i < len(s.attrs); i++ {
+			if s.attrs[i].name == index.value.(string) {
+				s.attrs[i].attr = &value
 			}
 		}
 
@@ -1002,15 +867,11 @@ func SetValue(ds *dataStore, val dataType, index dataType, value dataType) {
 			index = GetDsValue(ds, index)
 		}
 		if index.dataType != Int {
-			log.Fatal("Error in \"set\", expected \"Int\" found ", dataTypes[index.dataType])
+			throwError(TypeError, "Error in \"set\", expected \"Int\" found ", dataTypes[index.dataType])
 		}
-		idx := index.value.(int)
-		if idx >= len(list) {
-			log.Fatal("Index out of bounds ", index, " on list of length ", len(list))
-		}
-		val.value.([]dataType)[idx] = value
+		val.value.([]dataType)[itemIndex("set", index, len(list))] = value
 	} else {
-		log.Fatal("Error in \"set\", expected \"List\" or \"Struct\" found ", dataTypes[val.dataType])
+		throwError(TypeError, "Error in \"set\", expected \"List\" or \"Struct\" found ", dataTypes[val.dataType])
 	}
 
 	if setVar {
@@ -1024,7 +885,7 @@ func Or(ds *dataStore, params ...dataType) bool {
 			v = GetDsValue(ds, v)
 		}
 		if v.dataType != Bool {
-			log.Fatal("Error in \"or\", expected \"Bool\" found ", dataTypes[v.dataType])
+			throwError(TypeError, "Error in \"or\", expected \"Bool\" found ", dataTypes[v.dataType])
 		}
 		if v.value.(bool) {
 			return true
@@ -1038,14 +899,14 @@ func Not(ds *dataStore, val dataType) bool {
 		val = GetDsValue(ds, val)
 	}
 	if val.dataType != Bool {
-		log.Fatal("Error in \"not\", expected \"Bool\" found ", dataTypes[val.dataType])
+		throwError(TypeError, "Error in \"not\", expected \"Bool\" found ", dataTypes[val.dataType])
 	}
 	return !val.value.(bool)
 }
 
 func MakeFunction(ds *dataStore, scopes int, name dataType, data []dataType) *dataType {
 	if name.dataType != Ident {
-		log.Fatal("Function named " + fmt.Sprint(name.value) + " must be an Ident")
+		throwError(TypeError, "Function named "+fmt.Sprint(name.value)+" must be an Ident")
 		return nil
 	}
 
@@ -1055,126 +916,447 @@ func MakeFunction(ds *dataStore, scopes int, name dataType, data []dataType) *da
 		save = false
 	}
 
-	if scopes < len(ds.scopedFuncs) && StrArrIncludes(ds.scopedFuncs[scopes], nameStr) {
-		log.Fatal("Function already initialized: " + nameStr)
+	if IsReserved(ds, nameStr) {
+		throwError(NameError, "Function name \""+nameStr+"\" is reserved")
 		return nil
 	}
 
-	if StrArrIncludes(reserved, nameStr) {
-		log.Fatal("Function name \"" + nameStr + "\" is reserved")
+	e := GetEnv(ds, scopes)
+	paramData, returns := ReturnType("func", data[0:len(data)-1])
+	params, rest := GetParams("func", paramData)
+	returns = resolveTypes(ds, "func", params, returns)
+	f := function{name: nameStr, body: GetBody("func", data[len(data)-1]), params: params, rest: rest, returns: returns, env: e}
+
+	if save {
+		e.defineFunc(f)
 		return nil
 	}
+	return &dataType{value: f, dataType: Func}
+}
 
-	f := function{name: nameStr, body: data[len(data)-1].value.([]token), params: data[0 : len(data)-1]}
+// splits the return type off the params of a function, given as -> Type
+// after the params. returns is nil if there is none
+func ReturnType(name string, data []dataType) ([]dataType, *annotation) {
+	l := len(data)
+	if l < 2 || data[l-2].dataType != Ident || data[l-2].value != "->" {
+		return data, nil
+	}
+	if data[l-1].dataType != Ident {
+		throwError(SyntaxError, "Error in \"", name, "\", unknown type ", GetStrValue(data[l-1]))
+	}
+	return data[:l-2], newAnnotation(data[l-1].value.(string))
+}
 
-	if save {
-		ds.funcs[nameStr] = append(ds.funcs[nameStr], f)
-		for len(ds.scopedFuncs) < scopes {
-			ds.scopedFuncs = append(ds.scopedFuncs, []string{})
+// looks up the records and enums named by the annotations of a function
+// being made and returns its return type. An optional param given as
+// (name default) where default names one has it as its type instead
+func resolveTypes(ds *dataStore, name string, params []param, returns *annotation) *annotation {
+	for i := range params {
+		p := &params[i]
+		if p.typ == nil && p.def != nil && p.def.nodeType == IdentNode {
+			if a, ok := namedType(ds, p.def.value.value.(string)); ok {
+				p.typ, p.def = a, nil
+			}
+		} else if p.typ != nil {
+			p.typ = resolveType(ds, name, p.typ)
 		}
-		for len(ds.scopedRedefFuncs) < scopes {
-			ds.scopedRedefFuncs = append(ds.scopedRedefFuncs, []string{})
+	}
+	if returns != nil {
+		returns = resolveType(ds, name, returns)
+	}
+	return returns
+}
+
+func resolveType(ds *dataStore, name string, a *annotation) *annotation {
+	if !a.named() || a.rec != nil || a.enum != nil {
+		return a
+	}
+	res, ok := namedType(ds, a.name)
+	if !ok {
+		throwError(SyntaxError, "Error in \"", name, "\", unknown type ", a.name)
+	}
+	return res
+}
+
+// annotation for the record or enum called name, ok is false if there is
+// none
+func namedType(ds *dataStore, name string) (*annotation, bool) {
+	if _, ok := typeByName(name); ok {
+		return nil, false
+	}
+	if f, ok := LookupFunc(ds, name); ok && f.rec != nil {
+		return &annotation{name: name, t: Struct, rec: f.rec}, true
+	}
+	val, ok := lookupQualified(CurrentEnv(ds), name)
+	if v := CurrentEnv(ds).lookupVar(name); v != nil {
+		val, ok = v.data, true
+	}
+	if ok && val.dataType == Enum {
+		return &annotation{name: name, t: Variant, enum: val.value.(*union)}, true
+	}
+	return nil, false
+}
+
+// params of a function or macro made by name. Params after &optional can be
+// left out, given as name or (name default), and the one after &rest gets a
+// List of the args left over. A param can be a pattern like [a b] or {a b},
+// and (name Type) or (name Type default) checks the type of the arg
+func GetParams(name string, data []dataType) ([]param, string) {
+	params := []param{}
+	optional := false
+	for i := 0; i < len(data); i++ {
+		v := data[i]
+		if v.dataType == Ident && v.value == "&optional" {
+			if optional {
+				throwError(SyntaxError, "Error in \"", name, "\", &optional given twice")
+			}
+			optional = true
+			continue
 		}
-		if _, ok := ds.funcs[nameStr]; ok {
-			ds.scopedRedefFuncs[scopes-1] = append(ds.scopedRedefFuncs[scopes-1], nameStr)
-		} else {
-			ds.scopedFuncs[scopes-1] = append(ds.scopedFuncs[scopes-1], nameStr)
+		if v.dataType == Ident && v.value == "&rest" {
+			if i != len(data)-2 || data[i+1].dataType != Ident {
+				throwError(SyntaxError, "Error in \"", name, "\", expected one param name after &rest")
+			}
+			return params, data[i+1].value.(string)
 		}
-		return nil
+		if v.dataType == Ident && v.value == "->" {
+			throwError(SyntaxError, "Error in \"", name, "\", expected -> Type after the params")
+		}
+		if v.dataType == Body {
+			params = append(params, bodyParam(name, v.value.([]*node), optional))
+			continue
+		}
+		if v.dataType == Pattern {
+			params = append(params, param{name: GetStrValue(v), pattern: v.value.(*pattern), optional: optional})
+			continue
+		}
+		if v.dataType != Ident {
+			throwError(TypeError, "Error in \"", name, "\", expected \"Ident\" param found ", dataTypes[v.dataType])
+		}
+		params = append(params, param{name: v.value.(string), optional: optional})
 	}
-	return &dataType{value: f, dataType: Func}
+	return params, ""
 }
 
-func CallFunc(ds *dataStore, scopes int, name dataType, params []dataType) *[]dataType {
-	highestVarIndex := 0
-	varWithName := ds.vars[name.value.(string)]
-	funcWithName := ds.funcs[name.value.(string)]
-	ds.inFunc = true
-	var f function
-	for i := len(varWithName); i > 0; i-- {
-		v := varWithName[i-1]
-		if v.data.dataType == Func {
-			highestVarIndex = i - 1
-			break
+// param given as (name Type), (name default) or (name Type default), a
+// default needs the param to be optional. Type is a record or enum name if it
+// is not a type of value, which is looked up when the function is made
+func bodyParam(name string, nodes []*node, optional bool) param {
+	p := param{optional: optional}
+	if len(nodes) >= 2 && nodes[1].nodeType == IdentNode {
+		typeName := nodes[1].value.value.(string)
+		if _, ok := typeByName(typeName); ok || !optional || len(nodes) == 3 {
+			p.typ = newAnnotation(typeName)
+			nodes = append([]*node{nodes[0]}, nodes[2:]...)
+		}
+	}
+	if len(nodes) == 0 || len(nodes) > 2 {
+		throwError(SyntaxError, "Error in \"", name, "\", expected (param Type), (param default) or (param Type default)")
+	}
+	if len(nodes) == 2 {
+		if !optional {
+			throwError(SyntaxError, "Error in \"", name, "\", params with a default must come after &optional")
 		}
+		p.def = nodes[1]
 	}
-	if highestVarIndex > int(math.Max(float64(len(funcWithName)-1), 0)) {
-		f = varWithName[highestVarIndex].data.value.(function)
+	target := toPattern(nodes[0]).value
+	if target.dataType == Pattern {
+		p.name = GetStrValue(target)
+		p.pattern = target.value.(*pattern)
+	} else if nodes[0].nodeType == IdentNode {
+		p.name = target.value.(string)
 	} else {
-		if len(funcWithName) == 0 {
-			if name.dataType == Ident {
-				newName := GetDsValue(ds, name)
-				if newName.dataType == Func {
-					return CallInlineFunc(ds, scopes, name.value.(string), newName.value.(function), params)
-				} else if newName.dataType != String && newName.dataType != Ident {
-					log.Fatal("Unknown function: \"", newName.value, "\"")
-				}
-				newName.dataType = Ident
-				if b := IsBuiltin(ds, newName.value.(string)); b != nil {
-					return b.fn(ds, scopes, params)
-				} else {
-					return CallFunc(ds, scopes, newName, params)
-				}
-			}
-			log.Fatal("Unknown function: \"", name, "\"")
+		throwError(SyntaxError, "Error in \"", name, "\", expected a param name found ", GetStrValue(target))
+	}
+	return p
+}
+
+// params as they are given to func when it is called
+func paramData(nodes []*node) []dataType {
+	data := []dataType{}
+	for _, child := range nodes {
+		switch child.nodeType {
+		case BodyNode:
+			data = append(data, dataType{dataType: Body, value: child.children})
+		default:
+			data = append(data, child.value)
 		}
-		f = funcWithName[len(funcWithName)-1]
 	}
+	return data
+}
 
-	return CallInlineFunc(ds, scopes, name.value.(string), f, params)
+// binds args to the params of f in the scope at depth scopes+1, defaults are
+// evaluated in that scope so they can use the params before them
+func bindParams(ds *dataStore, scopes int, name string, f function, args []dataType) {
+	if msg := paramCountError(name, f, len(args)); msg != "" {
+		throwError(ArityError, msg)
+	}
+	for i, p := range f.params {
+		val := dataType{dataType: Nil, value: nil}
+		if i < len(args) {
+			val = args[i]
+		} else if p.def != nil {
+			val = evalNodeValue(ds, p.def, scopes)
+			RemoveScopedVars(ds, scopes+1)
+		}
+		// an optional param left out without a default is nil
+		if p.typ != nil && (i < len(args) || p.def != nil) && !p.typ.includes(val) {
+			throwError(TypeError, "Error in \"", name, "\", expected param ", p.name, " to be ", p.typ, " found ", GetType(ds, val))
+		}
+		if p.pattern != nil {
+			MakeVars(ds, scopes+1, dataType{dataType: Pattern, value: p.pattern}, val, false)
+		} else {
+			MakeVar(ds, scopes+1, p.name, val, false)
+		}
+	}
+	if f.rest != "" {
+		rest := []dataType{}
+		if len(args) > len(f.params) {
+			rest = append(rest, args[len(f.params):]...)
+		}
+		MakeVar(ds, scopes+1, f.rest, dataType{dataType: List, value: rest}, false)
+	}
+}
+
+// error message for calling f with n args, empty if n is allowed
+func paramCountError(name string, f function, n int) string {
+	missing := []string{}
+	for i, p := range f.params {
+		if i >= n && !p.optional {
+			missing = append(missing, p.name)
+		}
+	}
+	if len(missing) == 1 {
+		return fmt.Sprint("Error in \"", name, "\", missing param ", missing[0], ", expected ", paramCountStr(f), " found ", n)
+	} else if len(missing) > 0 {
+		return fmt.Sprint("Error in \"", name, "\", missing params ", strings.Join(missing, ", "), ", expected ", paramCountStr(f), " found ", n)
+	}
+	if n > len(f.params) && f.rest == "" {
+		return fmt.Sprint("Error in \"", name, "\", expected ", paramCountStr(f), " found ", n)
+	}
+	return ""
+}
+
+// "2 params", "1 to 3 params" or "2 or more params" for the args f takes
+func paramCountStr(f function) string {
+	required := 0
+	for _, p := range f.params {
+		if !p.optional {
+			required++
+		}
+	}
+	switch {
+	case f.rest != "":
+		return fmt.Sprint(required, " or more params")
+	case required == len(f.params):
+		return fmt.Sprint(required, " params")
+	}
+	return fmt.Sprint(required, " to ", len(f.params), " params")
+}
+
+// nearest function called name, either named or held by a variable
+func LookupFunc(ds *dataStore, name string) (function, bool) {
+	for e := CurrentEnv(ds); e != nil; e = e.parent {
+		if v, ok := e.vars[name]; ok && v.data.dataType == Func {
+			return v.data.value.(function), true
+		}
+		if f, ok := e.funcs[name]; ok {
+			return f, true
+		}
+	}
+	if v, ok := lookupQualified(CurrentEnv(ds), name); ok && v.dataType == Func {
+		return v.value.(function), true
+	}
+	return function{}, false
+}
+
+func CallFunc(ds *dataStore, scopes int, name dataType, params []dataType) *[]dataType {
+	ds.inFunc = true
+	nameStr := name.value.(string)
+	if f, ok := LookupFunc(ds, nameStr); ok {
+		return CallInlineFunc(ds, scopes, nameStr, f, params)
+	}
+
+	// a variable holding the name of a function calls that function
+	newName := GetDsValue(ds, name)
+	if (newName.dataType != String && newName.dataType != Ident) || newName.value == name.value {
+		throwError(NameError, "Unknown function: \"", newName.value, "\"")
+	}
+	newName.dataType = Ident
+	if b := calledBuiltin(ds, newName.value.(string)); b != nil {
+		return b.call(ds, scopes, params)
+	}
+	return CallFunc(ds, scopes, newName, params)
 }
 
 func CallInlineFunc(ds *dataStore, scopes int, name string, f function, params []dataType) *[]dataType {
-	if len(f.params) != len(params) {
-		log.Fatal("Error in \"", name, "\", expected ", len(f.params), " params found ", len(params))
+	if f.native != nil {
+		args := []dataType{}
+		for _, v := range params {
+			args = append(args, GetDsValue(ds, v))
+		}
+		return f.native(ds, args)
 	}
 
-	for i := 0; i < len(f.params); i++ {
-		MakeVar(ds, scopes+1, f.params[i].value.(string), GetDsValue(ds, params[i]), false)
+	// args are looked up in the scope of the caller before the body's scope
+	// replaces it
+	args := make([]dataType, len(params))
+	for i, v := range params {
+		args[i] = GetDsValue(ds, v)
 	}
-	toReturn := Eval(ds, f.body, scopes)
+	return CallWithArgs(ds, scopes, name, f, args)
+}
+
+// calls nested deeper than this raise an error, as running out of go stack
+// ends the process. It is above the depth ordinary recursion reached before
+// tail calls, the blisp command raises the go stack limit so it fits
+const maxCallDepth = 400000
+
+// runs the body of f with args bound to its params, tail calls made by the
+// body are run here in a loop instead of recursing so they use no go stack
+func CallWithArgs(ds *dataStore, scopes int, name string, f function, args []dataType) *[]dataType {
+	if ds.funcDepth >= maxCallDepth {
+		callTooDeep(name)
+	}
+	ds.funcDepth++
+	res := runBody(ds, scopes, name, f, args)
+	if _, ok := tailCallOf(res); ok || f.returns != nil {
+		res = runTailCalls(ds, scopes, name, f, res)
+	}
+	ds.funcDepth--
+	return res
+}
+
+// kept out of CallWithArgs, which is on the go stack once for every call
+// nested in another
+func callTooDeep(name string) {
+	throwError(RuntimeError, "Error in \"", name, "\", maximum call depth of ", maxCallDepth, " exceeded")
+}
+
+// runs the tail calls left by res, then checks the value of the last against
+// the return types of every function in the chain as each returns it
+func runTailCalls(ds *dataStore, scopes int, name string, f function, res *[]dataType) *[]dataType {
+	typed := []tailCall{}
+	if f.returns != nil {
+		typed = append(typed, tailCall{name: name, f: f})
+	}
+	for {
+		call, ok := tailCallOf(res)
+		if !ok {
+			break
+		}
+		if call.f.returns != nil {
+			typed = append(typed, call)
+		}
+		res = runTailCall(ds, scopes, call)
+	}
+	for _, call := range typed {
+		checkReturn(ds, call.name, call.f, res)
+	}
+	return res
+}
+
+// checks the value returned by a call to f against its return type
+func checkReturn(ds *dataStore, name string, f function, res *[]dataType) {
+	val := dataType{dataType: Nil, value: nil}
+	if res != nil && len(*res) > 0 {
+		val = (*res)[0]
+		if val.dataType == ReturnVal {
+			val = val.value.(dataType)
+		}
+	}
+	if !f.returns.includes(val) {
+		throwError(TypeError, "Error in \"", name, "\", expected return type ", f.returns, " found ", GetType(ds, val))
+	}
+}
+
+func runBody(ds *dataStore, scopes int, name string, f function, args []dataType) *[]dataType {
+	PushCallEnv(ds, scopes+1, f)
+	bindParams(ds, scopes, name, f, args)
+	if f.rec != nil {
+		ds.inFunc = false
+		return &[]dataType{f.rec.instance(ds)}
+	}
+	if f.tag != nil {
+		ds.inFunc = false
+		return &[]dataType{f.tag.instance(ds)}
+	}
+	toReturn := eval(ds, f.body, scopes)
 	ds.inFunc = false
 	return toReturn
 }
 
-func GetAndCompareNumbers(ds *dataStore, val1 dataType, val2 dataType, f func(num1 float64, num2 float64) bool) bool {
-	if val1.dataType == Ident {
-		val1 = GetDsValue(ds, val1)
+func runTailCall(ds *dataStore, scopes int, call tailCall) *[]dataType {
+	defer setErrorSite(call.name, call.pos)
+	return runBody(ds, scopes, call.name, call.f, call.args)
+}
+
+// call left by a body for its caller to make, either as the value of the last
+// expr or as the value of a return
+func tailCallOf(res *[]dataType) (tailCall, bool) {
+	if res == nil || len(*res) != 1 {
+		return tailCall{}, false
 	}
-	if val2.dataType == Ident {
-		val2 = GetDsValue(ds, val2)
+	val := (*res)[0]
+	if val.dataType == ReturnVal {
+		val = val.value.(dataType)
 	}
-	var num1 float64 = 0
-	var num2 float64 = 0
-	if val1.dataType == Int {
-		num1 = float64(val1.value.(int))
-	} else if val1.dataType == Float {
-		num1 = val1.value.(float64)
-	} else {
-		log.Fatal("Expected \"Int\" or \"Float\" found ", dataTypes[val1.dataType])
+	if val.dataType != TailCallVal {
+		return tailCall{}, false
+	}
+	return val.value.(tailCall), true
+}
+
+// TailCall value for calling info from tail position, ok is false for calls
+// to builtins and native functions which are made normally
+func GetTailCall(ds *dataStore, info []dataType, pos position) (dataType, bool) {
+	if len(info) == 0 {
+		return dataType{}, false
 	}
-	if val2.dataType == Int {
-		num2 = float64(val2.value.(int))
-	} else if val2.dataType == Float {
-		num2 = val2.value.(float64)
+	var f function
+	name := "lambda"
+	if info[0].dataType == Func {
+		f = info[0].value.(function)
+	} else if info[0].dataType == Ident {
+		name = info[0].value.(string)
+		if calledBuiltin(ds, name) != nil {
+			return dataType{}, false
+		}
+		var ok bool
+		if f, ok = LookupFunc(ds, name); !ok {
+			return dataType{}, false
+		}
 	} else {
-		log.Fatal("Expected \"Int\" or \"Float\" found ", dataTypes[val2.dataType])
+		return dataType{}, false
+	}
+	if f.native != nil {
+		return dataType{}, false
 	}
-	return f(num1, num2)
+	args := make([]dataType, len(info)-1)
+	for i, v := range info[1:] {
+		args[i] = GetDsValue(ds, v)
+	}
+	return dataType{dataType: TailCallVal, value: tailCall{name: name, f: f, args: args, pos: pos}}, true
 }
 
-func LessThan(ds *dataStore, val1 dataType, val2 dataType) bool {
-	comp := func(num1 float64, num2 float64) bool {
-		return num1 < num2
+func GetAndCompareNumbers(ds *dataStore, val1 dataType, val2 dataType, f func(comp int) bool) bool {
+	val1 = GetDsValue(ds, val1)
+	val2 = GetDsValue(ds, val2)
+	for _, v := range []dataType{val1, val2} {
+		if !isNumber(v) {
+			throwError(TypeError, "Expected \"Int\" or \"Float\" found ", dataTypes[v.dataType])
+		}
 	}
-	return GetAndCompareNumbers(ds, val1, val2, comp)
+	comp, ok := compareNumbers(val1, val2)
+	return ok && f(comp)
+}
+
+func LessThan(ds *dataStore, val1 dataType, val2 dataType) bool {
+	return GetAndCompareNumbers(ds, val1, val2, func(comp int) bool { return comp < 0 })
 }
 
 func LessThanOrEqualTo(ds *dataStore, val1 dataType, val2 dataType) bool {
-	comp := func(num1 float64, num2 float64) bool {
-		return num1 <= num2
-	}
-	return GetAndCompareNumbers(ds, val1, val2, comp)
+	return GetAndCompareNumbers(ds, val1, val2, func(comp int) bool { return comp <= 0 })
 }
 
 func GetFile(ds *dataStore, file dataType) string {
@@ -1182,13 +1364,13 @@ func GetFile(ds *dataStore, file dataType) string {
 		file = GetDsValue(ds, file)
 	}
 	if file.dataType != String {
-		log.Fatal("Error in \"read\", expected \"String\" found ", dataTypes[file.dataType])
+		throwError(TypeError, "Error in \"read\", expected \"String\" found ", dataTypes[file.dataType])
 	}
 	val, err := os.ReadFile(file.value.(string))
 	if err == nil {
 		return string(val)
 	} else {
-		log.Fatal(err)
+		throwError(IOError, err)
 	}
 	return ""
 }
@@ -1201,10 +1383,10 @@ func WriteFile(ds *dataStore, file dataType, data dataType) {
 		data = GetDsValue(ds, data)
 	}
 	if file.dataType != String {
-		log.Fatal("Error in \"read\", expected \"String\" found ", dataTypes[file.dataType])
+		throwError(TypeError, "Error in \"read\", expected \"String\" found ", dataTypes[file.dataType])
 	}
 	if data.dataType != String {
-		log.Fatal("Error in \"read\", expected \"String\" found ", dataTypes[file.dataType])
+		throwError(TypeError, "Error in \"read\", expected \"String\" found ", dataTypes[file.dataType])
 	}
 	fileStr := file.value.(string)
 	fileStr = fileStr[1 : len(fileStr)-1]
@@ -1212,7 +1394,7 @@ func WriteFile(ds *dataStore, file dataType, data dataType) {
 	dataStr = dataStr[1 : len(dataStr)-1]
 	err := os.WriteFile(fileStr, []byte(dataStr), 0666)
 	if err != nil {
-		log.Fatal(err)
+		throwError(IOError, err)
 	}
 }
 
@@ -1224,12 +1406,13 @@ func SubstrEnd(ds *dataStore, str dataType, index dataType) string {
 		index = GetDsValue(ds, index)
 	}
 	if str.dataType != String {
-		log.Fatal("Error in \"substr\", expected \"String\" found ", dataTypes[str.dataType])
+		throwError(TypeError, "Error in \"substr\", expected \"String\" found ", dataTypes[str.dataType])
 	}
 	if index.dataType != Int {
-		log.Fatal("Error in \"substr\", expected \"Int\" found ", dataTypes[index.dataType])
+		throwError(TypeError, "Error in \"substr\", expected \"Int\" found ", dataTypes[index.dataType])
 	}
-	return str.value.(string)[index.value.(int):]
+	s := str.value.(string)
+	return s[slicePos("substr", index, 0, len(s)):]
 }
 
 func Substr(ds *dataStore, str dataType, startIndex dataType, endIndex dataType) string {
@@ -1243,15 +1426,17 @@ func Substr(ds *dataStore, str dataType, startIndex dataType, endIndex dataType)
 		endIndex = GetDsValue(ds, endIndex)
 	}
 	if str.dataType != String {
-		log.Fatal("Error in \"substr\", expected \"String\" found ", dataTypes[str.dataType])
+		throwError(TypeError, "Error in \"substr\", expected \"String\" found ", dataTypes[str.dataType])
 	}
 	if startIndex.dataType != Int {
-		log.Fatal("Error in \"substr\", expected \"Int\" found ", dataTypes[startIndex.dataType])
+		throwError(TypeError, "Error in \"substr\", expected \"Int\" found ", dataTypes[startIndex.dataType])
 	}
 	if endIndex.dataType != Int {
-		log.Fatal("Error in \"substr\", expected \"Int\" found ", dataTypes[endIndex.dataType])
+		throwError(TypeError, "Error in \"substr\", expected \"Int\" found ", dataTypes[endIndex.dataType])
 	}
-	return str.value.(string)[startIndex.value.(int):endIndex.value.(int)]
+	s := str.value.(string)
+	start := slicePos("substr", startIndex, 0, len(s))
+	return s[start:slicePos("substr", endIndex, start, len(s))]
 }
 
 func GetType(ds *dataStore, val dataType) string {
@@ -1259,6 +1444,12 @@ func GetType(ds *dataStore, val dataType) string {
 		val = GetDsValue(ds, val)
 	}
 
+	if val.dataType == Struct && val.value.(structVal).rec != nil {
+		return val.value.(structVal).rec.name
+	}
+	if val.dataType == Variant {
+		return val.value.(variant).tag.String()
+	}
 	return dataTypes[val.dataType]
 }
 
@@ -1271,7 +1462,7 @@ func MakeStruct(ds *dataStore, params ...dataType) dataType {
 	for i, v := range params {
 		if i%2 == 0 {
 			if v.dataType != Ident && v.dataType != Int && v.dataType != Float && v.dataType != String && v.dataType != Bool {
-				log.Fatal("Expected \"Ident\" found ", dataTypes[v.dataType])
+				throwError(TypeError, "Expected \"Ident\" found ", dataTypes[v.dataType])
 			}
 			key = v
 		} else {
@@ -1279,7 +1470,7 @@ func MakeStruct(ds *dataStore, params ...dataType) dataType {
 			if info.dataType == Ident {
 				info = GetDsValue(ds, info)
 				if info.dataType == Ident {
-					log.Fatal("Unknown value: ", info.value.(string))
+					throwError(NameError, "Unknown value: ", info.value.(string))
 				}
 			}
 			if key.dataType == Ident || key.dataType == Int || key.dataType == Float || key.dataType == String || key.dataType == Bool {
@@ -1296,7 +1487,7 @@ func MakeStruct(ds *dataStore, params ...dataType) dataType {
 		}
 	}
 
-	d.value = m
+	d.value = structVal{attrs: m}
 	return d
 }
 
@@ -1305,19 +1496,8 @@ func Parse(ds *dataStore, str dataType) dataType {
 		str = GetDsValue(ds, str)
 	}
 	if str.dataType == String {
-		val, err := fastfloat.Parse(str.value.(string))
-		if err == nil {
-			if math.Floor(val) == val {
-				return dataType{
-					value:    int(val),
-					dataType: Int,
-				}
-			} else {
-				return dataType{
-					value:    val,
-					dataType: Float,
-				}
-			}
+		if num, ok := parseNumber(str.value.(string)); ok {
+			return num
 		}
 	}
 	return dataType{value: nil, dataType: Nil}
@@ -1332,7 +1512,7 @@ func Shift(ds *dataStore, arr dataType) dataType {
 		arr = GetDsValue(ds, arr)
 	}
 	if arr.dataType != List {
-		log.Fatal("Error in \"shift\", expected \"List\" found ", dataTypes[arr.dataType])
+		throwError(TypeError, "Error in \"shift\", expected \"List\" found ", dataTypes[arr.dataType])
 	}
 	list := arr.value.([]dataType)
 	if len(list) == 0 {
@@ -1356,101 +1536,54 @@ func CallProp(ds *dataStore, scopes int, params []dataType) *[]dataType {
 		obj = GetDsValue(ds, obj)
 	}
 	if obj.dataType != Struct {
-		log.Fatal("Error in \".\", expected \"Struct\" found ", dataTypes[obj.dataType])
+		throwError(TypeError, "Error in \".\", expected \"Struct\" found ", dataTypes[obj.dataType])
 	}
 	key := params[1]
 	if key.dataType != Ident {
-		log.Fatal("Error in \".\", expected \"Ident\" found ", dataTypes[obj.dataType])
+		throwError(TypeError, "Error in \".\", expected \"Ident\" found ", dataTypes[obj.dataType])
 	}
 
-	structAttrs := obj.value.([]structAttr)
-	index := 0
-	for i := 0; i < len(structAttrs); i++ {
-		if structAttrs[i].name == key.value.(string) {
-			index = i
-			break
-		}
+	args := []dataType{}
+	for _, v := range params[2:] {
+		args = append(args, GetDsValue(ds, v))
 	}
-	fn := structAttrs[index].attr
-	f := fn.value.(function)
-
-	if len(f.params) != len(params)-1 {
-		log.Fatal("Error in \".\", expected ", len(f.params)-1, " params found ", len(params)-2)
-	}
-
-	MakeVar(ds, scopes+1, f.params[0].value.(string), GetDsValue(ds, params[0]), false)
-	for i := 2; i < len(params); i++ {
-		MakeVar(ds, scopes+1, f.params[i-1].value.(string), GetDsValue(ds, params[i]), false)
-	}
-	ds.inFunc = false
-	return Eval(ds, f.body, scopes+1)
+	return callMethod(ds, scopes, obj, obj.value.(structVal), key.value.(string), args)
 }
 
 func WhileLoop(ds *dataStore, scopes int, params []dataType) *[]dataType {
-	conditionTokens := params[0].value.([]token)
+	condition := GetBody("while", params[0])
+	body := GetBody("while", params[1])
 
-	hasReturn := false
 	var resP *[]dataType = nil
-
-	if conditionTokens[0].tokenType == Identifier {
-		dt := dataType{dataType: Ident, value: conditionTokens[0].value.(string)}
-		condition := GetDsValue(ds, dt)
-		if condition.dataType != Bool {
-			log.Fatal("Error in \"while\", expected \"Bool\" found ", dataTypes[dt.dataType])
-		}
-		for condition.value.(bool) {
-			resP = Eval(ds, params[1].value.([]token), scopes+1)
-			if resP != nil {
-				res := *resP
-				if hasReturn && len(res) > 0 && (res[0].dataType == BreakVal || res[0].dataType == ReturnVal) {
-					if res[0].dataType == ReturnVal {
-						return &[]dataType{res[0]}
-					}
-					break
-				}
-			}
-			condition = GetDsValue(ds, dt)
-		}
-	} else if conditionTokens[0].tokenType == BoolToken {
-		if conditionTokens[0].value.(bool) {
-			for {
-				resP = Eval(ds, params[1].value.([]token), scopes+1)
-				if resP != nil {
-					res := *resP
-					if hasReturn && len(res) > 0 && (res[0].dataType == BreakVal || res[0].dataType == ReturnVal) {
-						if res[0].dataType == ReturnVal {
-							return &[]dataType{res[0]}
-						}
-						break
-					}
-				}
+	for {
+		var conditionVal dataType
+		if condition[0].nodeType == CallNode {
+			conditionP, _ := evalCall(ds, condition[0], scopes+1)
+			if conditionP == nil || len(*conditionP) == 0 {
+				throwError(TypeError, "Error in \"while\", expected \"Bool\" condition, found ", dataTypes[Nil])
 			}
+			conditionVal = (*conditionP)[0]
+		} else {
+			conditionVal = GetDsValue(ds, evalValue(ds, condition[0], scopes+1))
 		}
-	} else if conditionTokens[0].tokenType == OpenParen {
-		conditionP := Eval(ds, conditionTokens, scopes+1)
-		if conditionP != nil {
-			condition := *conditionP
-			if condition[0].dataType != Bool {
-				log.Fatal("Error in \"while\", expected \"Bool\" condition, found ", dataTypes[condition[0].dataType])
-			}
-			for condition[0].value.(bool) {
-				resP = Eval(ds, params[1].value.([]token), scopes+1)
-				if resP != nil {
-					res := *resP
-					if len(res) == 1 {
-						if res[0].dataType == BreakVal {
-							break
-						}
-						if res[0].dataType == ReturnVal {
-							return resP
-						}
-					}
+		if conditionVal.dataType != Bool {
+			throwError(TypeError, "Error in \"while\", expected \"Bool\" condition, found ", dataTypes[conditionVal.dataType])
+		}
+		if !conditionVal.value.(bool) {
+			break
+		}
+		resP = eval(ds, body, scopes+1)
+		if resP != nil {
+			res := *resP
+			if len(res) == 1 {
+				if res[0].dataType == BreakVal {
+					return nil
+				}
+				if res[0].dataType == ReturnVal {
+					return resP
 				}
-				condition = *Eval(ds, conditionTokens, scopes+1)
 			}
 		}
-	} else {
-		log.Fatal("Unexpected token ", conditionTokens[0].value)
 	}
 	return resP
 }
@@ -1463,8 +1596,8 @@ func AddOne(ds *dataStore, num dataType) dataType {
 		isIdent = true
 		num = GetDsValue(ds, num)
 	}
-	if num.dataType == Int {
-		num.value = num.value.(int) + 1
+	if num.dataType == Int || num.dataType == Rational || num.dataType == Decimal {
+		num = arith("+", num, dataType{dataType: Int, value: 1})
 		if isIdent {
 			SetVar(ds, name, num)
 		}
@@ -1476,7 +1609,7 @@ func AddOne(ds *dataStore, num dataType) dataType {
 		}
 		return num
 	} else {
-		log.Fatal("Error in \"++\", expected \"Int\" or \"Float\"")
+		throwError(TypeError, "Error in \"++\", expected ", typesStr(numberTypes), " found ", dataTypes[num.dataType])
 	}
 	return dataType{dataType: Nil, value: nil}
 }
@@ -1495,16 +1628,17 @@ func AddMany(ds *dataStore, num dataType, amount dataType) dataType {
 	}
 
 	var amountVal float64
-	if amount.dataType == Int {
-		amountVal = float64(amount.value.(int))
-	} else if amount.dataType == Float {
-		amountVal = amount.value.(float64)
+	if isNumber(amount) {
+		amountVal = floatOf(amount)
 	} else {
-		log.Fatal("Error in \"+=\", expected \"Int\" or \"Float\"")
+		throwError(TypeError, "Error in \"+=\", expected \"Int\" or \"Float\"")
 	}
 
-	if num.dataType == Int {
-		num.value = num.value.(int) + int(amountVal)
+	if num.dataType == Int || num.dataType == Rational || num.dataType == Decimal {
+		if amount.dataType == Float {
+			amount = truncNumber(amount)
+		}
+		num = arith("+", num, amount)
 		if isIdent {
 			SetVar(ds, name, num)
 		}
@@ -1516,7 +1650,7 @@ func AddMany(ds *dataStore, num dataType, amount dataType) dataType {
 		}
 		return num
 	} else {
-		log.Fatal("Error in \"+=\", expected \"Int\" or \"Float\"")
+		throwError(TypeError, "Error in \"+=\", expected ", typesStr(numberTypes), " found ", dataTypes[num.dataType])
 	}
 	return dataType{dataType: Nil, value: nil}
 }
@@ -1529,8 +1663,8 @@ func SubOne(ds *dataStore, num dataType) dataType {
 		isIdent = true
 		num = GetDsValue(ds, num)
 	}
-	if num.dataType == Int {
-		num.value = num.value.(int) - 1
+	if num.dataType == Int || num.dataType == Rational || num.dataType == Decimal {
+		num = arith("-", num, dataType{dataType: Int, value: 1})
 		if isIdent {
 			SetVar(ds, name, num)
 		}
@@ -1542,7 +1676,7 @@ func SubOne(ds *dataStore, num dataType) dataType {
 		}
 		return num
 	} else {
-		log.Fatal("Error in \"++\", expected \"Int\" or \"Float\"")
+		throwError(TypeError, "Error in \"--\", expected ", typesStr(numberTypes), " found ", dataTypes[num.dataType])
 	}
 	return dataType{dataType: Nil, value: nil}
 }
@@ -1561,16 +1695,17 @@ func SubMany(ds *dataStore, num dataType, amount dataType) dataType {
 	}
 
 	var amountVal float64
-	if amount.dataType == Int {
-		amountVal = float64(amount.value.(int))
-	} else if amount.dataType == Float {
-		amountVal = amount.value.(float64)
+	if isNumber(amount) {
+		amountVal = floatOf(amount)
 	} else {
-		log.Fatal("Error in \"+=\", expected \"Int\" or \"Float\"")
+		throwError(TypeError, "Error in \"+=\", expected \"Int\" or \"Float\"")
 	}
 
-	if num.dataType == Int {
-		num.value = num.value.(int) - int(amountVal)
+	if num.dataType == Int || num.dataType == Rational || num.dataType == Decimal {
+		if amount.dataType == Float {
+			amount = truncNumber(amount)
+		}
+		num = arith("-", num, amount)
 		if isIdent {
 			SetVar(ds, name, num)
 		}
@@ -1582,7 +1717,7 @@ func SubMany(ds *dataStore, num dataType, amount dataType) dataType {
 		}
 		return num
 	} else {
-		log.Fatal("Error in \"+=\", expected \"Int\" or \"Float\"")
+		throwError(TypeError, "Error in \"-=\", expected ", typesStr(numberTypes), " found ", dataTypes[num.dataType])
 	}
 	return dataType{dataType: Nil, value: nil}
 }
@@ -1591,15 +1726,15 @@ func FromCharCode(ds *dataStore, charCode dataType) dataType {
 	if charCode.dataType == Ident {
 		charCode = GetDsValue(ds, charCode)
 		if charCode.dataType == Ident {
-			log.Fatal("Error in \"from-char-code\", expected \"Int\" found ", dataTypes[charCode.dataType])
+			throwError(TypeError, "Error in \"from-char-code\", expected \"Int\" found ", dataTypes[charCode.dataType])
 		}
 	}
 
 	if charCode.dataType == Int {
-		val := charCode.value.(int)
+		val := intOf("from-char-code", charCode)
 		return dataType{dataType: String, value: string(rune(val))}
 	} else {
-		log.Fatal("Error in \"from-char-code\", expected \"Int\" found ", dataTypes[charCode.dataType])
+		throwError(TypeError, "Error in \"from-char-code\", expected \"Int\" found ", dataTypes[charCode.dataType])
 	}
 	return dataType{dataType: Nil, value: nil}
 }
@@ -1608,18 +1743,18 @@ func CharCodeFrom(ds *dataStore, charCode dataType) dataType {
 	if charCode.dataType == Ident {
 		charCode = GetDsValue(ds, charCode)
 		if charCode.dataType == Ident {
-			log.Fatal("Error in \"char-code-from\", expected \"String\" found ", dataTypes[charCode.dataType])
+			throwError(TypeError, "Error in \"char-code-from\", expected \"String\" found ", dataTypes[charCode.dataType])
 		}
 	}
 
 	if charCode.dataType == String {
 		val := charCode.value.(string)
 		if len(val) > 1 {
-			log.Fatal("Error in \"char-code-from\", expected \"String\" of length 1, found length ", len(val))
+			throwError(TypeError, "Error in \"char-code-from\", expected \"String\" of length 1, found length ", len(val))
 		}
 		return dataType{dataType: Int, value: int([]rune(val)[0])}
 	} else {
-		log.Fatal("Error in \"char-code-from\", expected \"String\" found ", dataTypes[charCode.dataType])
+		throwError(TypeError, "Error in \"char-code-from\", expected \"String\" found ", dataTypes[charCode.dataType])
 	}
 	return dataType{dataType: Nil, value: nil}
 }
@@ -1628,14 +1763,14 @@ func Split(ds *dataStore, val dataType, splitBy dataType) dataType {
 	if val.dataType == Ident {
 		val = GetDsValue(ds, val)
 		if val.dataType == Ident {
-			log.Fatal("Error in \"split\", expected \"String\" found ", dataTypes[val.dataType])
+			throwError(TypeError, "Error in \"split\", expected \"String\" found ", dataTypes[val.dataType])
 		}
 	}
 
 	if splitBy.dataType == Ident {
 		splitBy = GetDsValue(ds, splitBy)
 		if splitBy.dataType == Ident {
-			log.Fatal("Error in \"split\", expected \"String\" found ", dataTypes[splitBy.dataType])
+			throwError(TypeError, "Error in \"split\", expected \"String\" found ", dataTypes[splitBy.dataType])
 		}
 	}
 
@@ -1648,10 +1783,10 @@ func Split(ds *dataStore, val dataType, splitBy dataType) dataType {
 			}
 			return dataType{dataType: List, value: res}
 		} else {
-			log.Fatal("Error in \"split\", expected \"String\" found ", dataTypes[val.dataType])
+			throwError(TypeError, "Error in \"split\", expected \"String\" found ", dataTypes[val.dataType])
 		}
 	} else {
-		log.Fatal("Error in \"split\", expected \"String\" found ", dataTypes[val.dataType])
+		throwError(TypeError, "Error in \"split\", expected \"String\" found ", dataTypes[val.dataType])
 	}
 
 	return dataType{dataType: Nil, value: nil}
@@ -1666,11 +1801,18 @@ func GetKeys(ds *dataStore, obj dataType) dataType {
 	if obj.dataType == Ident {
 		obj = GetDsValue(ds, obj)
 	}
+	if obj.dataType == Map {
+		res := []dataType{}
+		for _, entry := range obj.value.(*hashMap).Entries() {
+			res = append(res, entry.key)
+		}
+		return dataType{dataType: List, value: res}
+	}
 	if obj.dataType != Struct {
-		log.Fatal("Error in \"keys\", expected \"Struct\" found ", dataTypes[obj.dataType])
+		throwError(TypeError, "Error in \"keys\", expected \"Struct\" or \"Map\" found ", dataTypes[obj.dataType])
 	}
-	keys := obj.value.([]structAttr)
-	res := make([]dataType, (len(keys)-1)/2)
+	keys := obj.value.(structVal).attrs
+	res := make([]dataType, 0, len(keys))
 	for _, key := range keys {
 		res = append(res, dataType{dataType: String, value: key.name})
 	}
@@ -1681,13 +1823,20 @@ func GetValues(ds *dataStore, obj dataType) dataType {
 	if obj.dataType == Ident {
 		obj = GetDsValue(ds, obj)
 	}
+	if obj.dataType == Map {
+		res := []dataType{}
+		for _, entry := range obj.value.(*hashMap).Entries() {
+			res = append(res, entry.value)
+		}
+		return dataType{dataType: List, value: res}
+	}
 	if obj.dataType != Struct {
-		log.Fatal("Error in \"values\", expected \"Struct\" found ", dataTypes[obj.dataType])
+		throwError(TypeError, "Error in \"values\", expected \"Struct\" or \"Map\" found ", dataTypes[obj.dataType])
 	}
-	keys := obj.value.([]structAttr)
-	res := make([]dataType, len(keys))
+	keys := obj.value.(structVal).attrs
+	res := make([]dataType, 0, len(keys))
 	for _, key := range keys {
-		res = append(res, dataType{dataType: String, value: *key.attr})
+		res = append(res, *key.attr)
 	}
 	return dataType{dataType: List, value: res}
 }
@@ -1699,8 +1848,11 @@ func Floor(ds *dataStore, val dataType) dataType {
 	if val.dataType == Int {
 		return val
 	}
+	if val.dataType == Rational || val.dataType == Decimal {
+		return roundNumber(val, false)
+	}
 	if val.dataType != Float {
-		log.Fatal("Error in \"floor\", expected \"Float\" found ", dataTypes[val.dataType])
+		throwError(TypeError, "Error in \"floor\", expected \"Float\" found ", dataTypes[val.dataType])
 	}
 	return dataType{dataType: Float, value: math.Floor(val.value.(float64))}
 }
@@ -1712,8 +1864,11 @@ func Ceil(ds *dataStore, val dataType) dataType {
 	if val.dataType == Int {
 		return val
 	}
+	if val.dataType == Rational || val.dataType == Decimal {
+		return roundNumber(val, true)
+	}
 	if val.dataType != Float {
-		log.Fatal("Error in \"ceil\", expected \"Float\" found ", dataTypes[val.dataType])
+		throwError(TypeError, "Error in \"ceil\", expected \"Float\" found ", dataTypes[val.dataType])
 	}
 	return dataType{dataType: Float, value: math.Ceil(val.value.(float64))}
 }
@@ -1725,10 +1880,10 @@ func CastFloat(ds *dataStore, val dataType) dataType {
 	if val.dataType == Float {
 		return val
 	}
-	if val.dataType != Int {
-		log.Fatal("Error in \"float\", expected \"Int\" found ", dataTypes[val.dataType])
+	if !isNumber(val) {
+		throwError(TypeError, "Error in \"float\", expected a number found ", dataTypes[val.dataType])
 	}
-	return dataType{dataType: Float, value: float64(val.value.(int))}
+	return dataType{dataType: Float, value: floatOf(val)}
 }
 
 func CastInt(ds *dataStore, val dataType) dataType {
@@ -1738,10 +1893,13 @@ func CastInt(ds *dataStore, val dataType) dataType {
 	if val.dataType == Int {
 		return val
 	}
-	if val.dataType != Float {
-		log.Fatal("Error in \"int\", expected \"Float\" found ", dataTypes[val.dataType])
+	if !isNumber(val) {
+		throwError(TypeError, "Error in \"int\", expected a number found ", dataTypes[val.dataType])
+	}
+	if f, ok := val.value.(float64); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+		throwError(TypeError, "Error in \"int\", cannot convert ", f, " to \"Int\"")
 	}
-	return dataType{dataType: Int, value: int(val.value.(float64))}
+	return truncNumber(val)
 }
 
 func CastString(ds *dataStore, val dataType) dataType {
@@ -0,0 +1,331 @@
+package blisp
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+)
+
+// SymbolValue is a blisp Symbol in Go, like done for 'done
+type SymbolValue string
+
+// VariantValue is a blisp Variant in Go, Tag is its tag written as Type/tag
+// and Values are the values of its fields in order
+type VariantValue struct {
+	Tag    string
+	Values []any
+}
+
+func (v VariantValue) String() string {
+	if len(v.Values) == 0 {
+		return v.Tag
+	}
+	return fmt.Sprint(append([]any{"(" + v.Tag}, v.Values...)...) + ")"
+}
+
+// EnumValue is a type declared with enum or variant in Go, with the names of
+// its tags in the order they were declared
+type EnumValue struct {
+	Name string
+	Tags []string
+}
+
+func (e EnumValue) String() string {
+	return "<enum " + e.Name + ">"
+}
+
+// NativeFunc is a Go function that can be called from blisp code. Arguments
+// and return values are converted with the same rules as SetGlobal and
+// GetGlobal.
+type NativeFunc func(args ...any) (any, error)
+
+// Interpreter is an embeddable blisp runtime. Each Interpreter has its own
+// variables, functions and builtins, so several can be used side by side.
+type Interpreter struct {
+	ds *dataStore
+}
+
+// New creates an Interpreter with all builtins loaded.
+func New() *Interpreter {
+	return &Interpreter{ds: newDataStore()}
+}
+
+// UseVM switches between compiling blocks to bytecode for the stack vm and
+// walking the syntax tree directly, the tree walker is the default.
+func (in *Interpreter) UseVM(enabled bool) {
+	in.ds.useVM = enabled
+}
+
+// UseExact makes dividing Ints that do not divide exactly give a Rational
+// instead of a Float, like (exact-mode true).
+func (in *Interpreter) UseExact(enabled bool) {
+	in.ds.exact = enabled
+}
+
+// EvalString evaluates blisp source and returns the value of the last
+// top level expression converted to a Go value. A call to exit stops it with
+// an *ExitError instead of ending the process.
+func (in *Interpreter) EvalString(code string) (any, error) {
+	return in.evalSource("<string>", code)
+}
+
+// EvalFile reads and evaluates a blisp source file.
+func (in *Interpreter) EvalFile(path string) (any, error) {
+	dat, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		// requires in the file are found relative to it, and requiring
+		// it again is a cycle
+		in.ds.files = append(in.ds.files, abs)
+		defer func() { in.ds.files = in.ds.files[:len(in.ds.files)-1] }()
+	}
+	return in.evalSource(path, string(dat))
+}
+
+func (in *Interpreter) evalSource(file string, code string) (any, error) {
+	nodes, err := parse(file, code)
+	if err != nil {
+		return nil, err
+	}
+	res, err := Eval(in.ds, nodes, 0)
+	if err != nil {
+		return nil, err
+	}
+	if res == nil || len(*res) == 0 {
+		return nil, nil
+	}
+	return in.toGo((*res)[0]), nil
+}
+
+// SetGlobal binds name to value in the global scope, converting value to a
+// blisp value first. A NativeFunc is bound as a callable function.
+func (in *Interpreter) SetGlobal(name string, value any) (err error) {
+	defer catchError(&err)
+	data, err := in.fromGo(value)
+	if err != nil {
+		return err
+	}
+	if f, ok := data.value.(function); ok && f.native != nil {
+		f.name = name
+		data.value = f
+	}
+	MakeVar(in.ds, 1, name, data, false)
+	return nil
+}
+
+// GetGlobal looks up name and returns its value converted to a Go value.
+// The second result is false if name is not defined.
+func (in *Interpreter) GetGlobal(name string) (any, bool) {
+	val := GetDsValue(in.ds, dataType{dataType: Ident, value: name})
+	if val.dataType == Ident {
+		return nil, false
+	}
+	return in.toGo(val), true
+}
+
+// toGo converts a blisp value to the closest Go value:
+// Int -> int (*big.Int if it does not fit), Float -> float64,
+// Rational and Decimal -> *big.Rat, String -> string, Bool -> bool,
+// Nil -> nil, List -> []any, Struct -> map[string]any, Map -> map[any]any
+// and Func -> NativeFunc
+func (in *Interpreter) toGo(data dataType) any {
+	switch data.dataType {
+	case Int, Float, String, Bool, Ident:
+		return data.value
+	case Rational, Decimal:
+		return new(big.Rat).Set(ratOf(data))
+	case Symbol:
+		return SymbolValue(data.value.(string))
+	case Enum:
+		u := data.value.(*union)
+		res := EnumValue{Name: u.name, Tags: []string{}}
+		for _, t := range u.tags {
+			res.Tags = append(res.Tags, t.name)
+		}
+		return res
+	case Variant:
+		v := data.value.(variant)
+		res := VariantValue{Tag: v.tag.String(), Values: []any{}}
+		for _, val := range v.values {
+			res.Values = append(res.Values, in.toGo(val))
+		}
+		return res
+	case List:
+		res := []any{}
+		for _, v := range data.value.([]dataType) {
+			res = append(res, in.toGo(v))
+		}
+		return res
+	case Struct:
+		res := map[string]any{}
+		for _, attr := range data.value.(structVal).attrs {
+			res[attr.name] = in.toGo(*attr.attr)
+		}
+		return res
+	case Map:
+		res := map[any]any{}
+		for _, entry := range data.value.(*hashMap).Entries() {
+			res[in.toGo(entry.key)] = in.toGo(entry.value)
+		}
+		return res
+	case Func:
+		f := data.value.(function)
+		return NativeFunc(func(args ...any) (any, error) {
+			params := []dataType{}
+			for _, arg := range args {
+				param, err := in.fromGo(arg)
+				if err != nil {
+					return nil, err
+				}
+				params = append(params, param)
+			}
+			return in.call(f, params)
+		})
+	}
+	return nil
+}
+
+// calls a blisp function from go, recovering any runtime failure as an error
+func (in *Interpreter) call(f function, params []dataType) (res any, err error) {
+	// the function may be called from a native function while blisp code is
+	// running, so the call goes below the scopes that are already there
+	scopes := len(in.ds.frames)
+	funcDepth := in.ds.funcDepth
+	defer func() {
+		if r := recover(); r != nil {
+			err = toError(r)
+			RemoveScopedVars(in.ds, scopes)
+			in.ds.inFunc = false
+			in.ds.funcDepth = funcDepth
+		}
+	}()
+	valP := CallInlineFunc(in.ds, scopes, f.name, f, params)
+	RemoveScopedVars(in.ds, scopes)
+	if valP == nil || len(*valP) == 0 {
+		return nil, nil
+	}
+	val := (*valP)[0]
+	if val.dataType == ReturnVal {
+		val = val.value.(dataType)
+	}
+	return in.toGo(val), nil
+}
+
+// fromGo converts a Go value to a blisp value, the reverse of toGo
+func (in *Interpreter) fromGo(value any) (dataType, error) {
+	switch v := value.(type) {
+	case nil:
+		return dataType{dataType: Nil, value: nil}, nil
+	case dataType:
+		return v, nil
+	case bool:
+		return dataType{dataType: Bool, value: v}, nil
+	case *big.Int:
+		return intValue(new(big.Int).Set(v)), nil
+	case *big.Rat:
+		return ratValue(new(big.Rat).Set(v)), nil
+	case string:
+		return dataType{dataType: String, value: v}, nil
+	case SymbolValue:
+		return dataType{dataType: Symbol, value: string(v)}, nil
+	case EnumValue:
+		if u := CurrentEnv(in.ds).lookupVar(v.Name); u != nil && u.data.dataType == Enum {
+			return u.data, nil
+		}
+		return dataType{}, fmt.Errorf("no enum named %s", v.Name)
+	case VariantValue:
+		return in.fromVariant(v)
+	case NativeFunc:
+		return in.nativeFunc(v), nil
+	case func(args ...any) (any, error):
+		return in.nativeFunc(v), nil
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return dataType{dataType: Int, value: int(rv.Int())}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return intValue(new(big.Int).SetUint64(rv.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return dataType{dataType: Float, value: rv.Float()}, nil
+	case reflect.Slice, reflect.Array:
+		res := []dataType{}
+		for i := 0; i < rv.Len(); i++ {
+			item, err := in.fromGo(rv.Index(i).Interface())
+			if err != nil {
+				return dataType{}, err
+			}
+			res = append(res, item)
+		}
+		return dataType{dataType: List, value: res}, nil
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return dataType{}, fmt.Errorf("cannot convert %T to a blisp value, map keys must be strings", value)
+		}
+		keys := []string{}
+		for _, key := range rv.MapKeys() {
+			keys = append(keys, key.String())
+		}
+		sort.Strings(keys)
+		attrs := []structAttr{}
+		for _, key := range keys {
+			item, err := in.fromGo(rv.MapIndex(reflect.ValueOf(key).Convert(rv.Type().Key())).Interface())
+			if err != nil {
+				return dataType{}, err
+			}
+			attrs = append(attrs, structAttr{name: key, attr: &item})
+		}
+		return dataType{dataType: Struct, value: structVal{attrs: attrs}}, nil
+	}
+	return dataType{}, fmt.Errorf("cannot convert %T to a blisp value", value)
+}
+
+// variant for v, its tag is looked up by name in the global scope
+func (in *Interpreter) fromVariant(v VariantValue) (dataType, error) {
+	t := lookupTag(in.ds, v.Tag)
+	if t == nil {
+		return dataType{}, fmt.Errorf("no enum tag named %s", v.Tag)
+	}
+	if len(v.Values) != len(t.fields) {
+		return dataType{}, fmt.Errorf("%s has %d fields, found %d values", v.Tag, len(t.fields), len(v.Values))
+	}
+	values := []dataType{}
+	for _, value := range v.Values {
+		val, err := in.fromGo(value)
+		if err != nil {
+			return dataType{}, err
+		}
+		values = append(values, val)
+	}
+	return dataType{dataType: Variant, value: variant{tag: t, values: values}}, nil
+}
+
+func (in *Interpreter) nativeFunc(fn NativeFunc) dataType {
+	f := function{
+		name: "native",
+		native: func(ds *dataStore, params []dataType) *[]dataType {
+			args := []any{}
+			for _, v := range params {
+				args = append(args, in.toGo(v))
+			}
+			res, err := fn(args...)
+			if blispErr, ok := err.(*BlispError); ok {
+				panic(blispErr)
+			} else if err != nil {
+				throwError(RuntimeError, err)
+			}
+			val, err := in.fromGo(res)
+			if err != nil {
+				throwError(TypeError, err)
+			}
+			return &[]dataType{val}
+		},
+	}
+	return dataType{dataType: Func, value: f}
+}
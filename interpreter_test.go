@@ -0,0 +1,65 @@
+package blisp
+
+import (
+	"reflect"
+	"testing"
+)
+
+// the value of the last expr is returned whatever kind of node it is
+func TestEvalStringValue(t *testing.T) {
+	tests := []struct {
+		code string
+		want any
+	}{
+		{"(+ 1 2)", 3},
+		{"(var x 4) x", 4},
+		{`"a"`, "a"},
+		{"5", 5},
+		{"'done", SymbolValue("done")},
+		{"[1 'a]", []any{1, SymbolValue("a")}},
+		{"(enum Shape (circle r) square) Shape", EnumValue{Name: "Shape", Tags: []string{"circle", "square"}}},
+		{"(enum Shape (circle r) square) (Shape/circle 2)", VariantValue{Tag: "Shape/circle", Values: []any{2}}},
+		{"(enum Shape (circle r) square) Shape/square", VariantValue{Tag: "Shape/square", Values: []any{}}},
+	}
+	for _, test := range tests {
+		got, err := New().EvalString(test.code)
+		if err != nil {
+			t.Errorf("%s: %v", test.code, err)
+		} else if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %#v, want %#v", test.code, got, test.want)
+		}
+	}
+}
+
+func TestSetGlobalConversions(t *testing.T) {
+	in := New()
+	if _, err := in.EvalString("(enum Shape (circle r) square)"); err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range map[string]any{
+		"sym":    SymbolValue("done"),
+		"circle": VariantValue{Tag: "Shape/circle", Values: []any{3}},
+		"shape":  EnumValue{Name: "Shape"},
+	} {
+		if err := in.SetGlobal(name, value); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	got, err := in.EvalString(`[(type sym) (match circle ((Shape/circle r) r)) (type shape)]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []any{"Symbol", 3, "Enum"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+	if err := in.SetGlobal("bad", VariantValue{Tag: "Shape/circle"}); err == nil {
+		t.Error("expected an error for a variant missing its fields")
+	}
+}
+
+func TestExitError(t *testing.T) {
+	_, err := New().EvalString(`(print "a") (exit 3) (print "b")`)
+	if exit, ok := err.(*ExitError); !ok || exit.Code != 3 {
+		t.Errorf("got %v, want exit status 3", err)
+	}
+}
@@ -0,0 +1,203 @@
+package blisp
+
+// code as data, a call is a List of its parts, an ident is a Symbol, a list
+// [a b] is the call (list a b) and a quote 'x is the call (quote x)
+func nodeToData(n *node) dataType {
+	switch n.nodeType {
+	case CallNode:
+		return nodesToData(n.written())
+	case BodyNode:
+		return formToData("body", n.children)
+	case ListNode:
+		return formToData("list", n.children)
+	case QuoteNode:
+		return formToData("quote", n.children)
+	case QuasiquoteNode:
+		return formToData("quasiquote", n.children)
+	case IdentNode:
+		return dataType{dataType: Symbol, value: n.value.value}
+	}
+	return n.value
+}
+
+// children of a call as the user wrote them, before the parser rewrote them
+func (n *node) written() []*node {
+	if n.source != nil {
+		return n.source
+	}
+	return n.children
+}
+
+func nodesToData(nodes []*node) dataType {
+	res := make([]dataType, len(nodes))
+	for i, n := range nodes {
+		res[i] = nodeToData(n)
+	}
+	return dataType{dataType: List, value: res}
+}
+
+func formToData(name string, nodes []*node) dataType {
+	return formOf(name, nodesToData(nodes).value.([]dataType)...)
+}
+
+// the List (name items...)
+func formOf(name string, items ...dataType) dataType {
+	res := []dataType{{dataType: Symbol, value: name}}
+	return dataType{dataType: List, value: append(res, items...)}
+}
+
+// the reverse of nodeToData, other values are kept as they are so a macro
+// can put any value in the code it returns
+func dataToNode(val dataType, pos position) *node {
+	switch val.dataType {
+	case List:
+		items := val.value.([]dataType)
+		children := make([]*node, len(items))
+		for i, item := range items {
+			children[i] = dataToNode(item, pos)
+		}
+		return newCallNode(children, pos)
+	case Symbol:
+		return &node{nodeType: IdentNode, value: dataType{dataType: Ident, value: val.value}, pos: pos}
+	case Ident:
+		return &node{nodeType: IdentNode, value: val, pos: pos}
+	}
+	return &node{nodeType: LiteralNode, value: val, pos: pos}
+}
+
+// value of the code in a quasiquote, level counts the quasiquotes the code
+// is nested in past the first one, only unquotes at level 0 are evaluated
+func evalQuasiquote(ds *dataStore, n *node, scopes int, level int) dataType {
+	switch n.nodeType {
+	case QuasiquoteNode:
+		return formOf("quasiquote", evalQuasiquote(ds, n.children[0], scopes, level+1))
+	case QuoteNode:
+		return formOf("quote", evalQuasiquote(ds, n.children[0], scopes, level))
+	case CallNode:
+		if name, ok := unquoteOf(n); ok && name == "unquote" {
+			if level == 0 {
+				return evalNodeValue(ds, n.children[1], scopes)
+			}
+			return formOf("unquote", evalQuasiquote(ds, n.children[1], scopes, level-1))
+		}
+		return quasiquoteList(ds, []dataType{}, n.written(), scopes, level)
+	case BodyNode:
+		return quasiquoteList(ds, formOf("body").value.([]dataType), n.children, scopes, level)
+	case ListNode:
+		return quasiquoteList(ds, formOf("list").value.([]dataType), n.children, scopes, level)
+	}
+	return nodeToData(n)
+}
+
+func quasiquoteList(ds *dataStore, res []dataType, nodes []*node, scopes int, level int) dataType {
+	for _, child := range nodes {
+		if name, ok := unquoteOf(child); ok && name == "unquote-splicing" && level == 0 {
+			val := evalNodeValue(ds, child.children[1], scopes)
+			if val.dataType != List {
+				throwErrorAt(TypeError, child.pos, "Error in \"unquote-splicing\", expected \"List\" found ", dataTypes[val.dataType])
+			}
+			res = append(res, val.value.([]dataType)...)
+			continue
+		}
+		res = append(res, evalQuasiquote(ds, child, scopes, level))
+	}
+	return dataType{dataType: List, value: res}
+}
+
+// name of the unquote form n is, if it is one
+func unquoteOf(n *node) (string, bool) {
+	if n.nodeType != CallNode || len(n.children) != 2 || n.children[0].nodeType != IdentNode {
+		return "", false
+	}
+	name := n.children[0].value.value.(string)
+	return name, name == "unquote" || name == "unquote-splicing"
+}
+
+// value of a single node, a call gives its first value
+func evalNodeValue(ds *dataStore, n *node, scopes int) dataType {
+	if n.nodeType == CallNode {
+		valP, _ := evalCall(ds, n, scopes+1)
+		if valP == nil || len(*valP) == 0 {
+			return dataType{dataType: Nil, value: nil}
+		}
+		return GetDsValue(ds, (*valP)[0])
+	}
+	return GetDsValue(ds, evalValue(ds, n, scopes))
+}
+
+func MakeMacro(ds *dataStore, scopes int, name dataType, data []dataType) {
+	if name.dataType != Ident {
+		throwError(TypeError, "Macro named ", name.value, " must be an Ident")
+	}
+	nameStr := name.value.(string)
+	if IsReserved(ds, nameStr) {
+		throwError(NameError, "Macro name \"", nameStr, "\" is reserved")
+	}
+	if len(data) == 0 {
+		throwError(ArityError, "Error in \"macro\", expected a body")
+	}
+	params, rest := GetParams("macro", data[:len(data)-1])
+	resolveTypes(ds, "macro", params, nil)
+	ds.macros[nameStr] = function{name: nameStr, body: GetBody("macro", data[len(data)-1]), params: params, rest: rest, env: GetEnv(ds, scopes)}
+}
+
+// code the macro call n expands to, nil if n does not call a macro. The
+// macro is called with its params as code the first time, after that the
+// expansion is reused
+func GetMacroExpansion(ds *dataStore, n *node, scopes int) *node {
+	if n.expansion != nil {
+		return n.expansion
+	}
+	if len(ds.macros) == 0 || len(n.children) == 0 || n.children[0].nodeType != IdentNode {
+		return nil
+	}
+	name := n.children[0].value.value.(string)
+	m, ok := ds.macros[name]
+	if !ok {
+		return nil
+	}
+	n.expansion = expandMacro(ds, n, scopes, name, m)
+	if n.tail {
+		markTail(n.expansion)
+	}
+	return n.expansion
+}
+
+func expandMacro(ds *dataStore, n *node, scopes int, name string, m function) *node {
+	defer setErrorSite(name, n.pos)
+	args := make([]dataType, len(n.children)-1)
+	for i, child := range n.children[1:] {
+		args[i] = nodeToData(child)
+	}
+	valP := CallWithArgs(ds, scopes, name, m, args)
+	RemoveScopedVars(ds, scopes)
+	val := dataType{dataType: Nil, value: nil}
+	if valP != nil && len(*valP) > 0 {
+		val = (*valP)[0]
+		if val.dataType == ReturnVal {
+			val = val.value.(dataType)
+		} else if val.dataType == BreakVal {
+			val = dataType{dataType: Nil, value: nil}
+		}
+	}
+	return dataToNode(val, n.pos)
+}
+
+// evaluates the expansion of a macro call at scope depth scopes, returns the
+// same as evalCall
+func evalExpansion(ds *dataStore, exp *node, scopes int) (*[]dataType, bool) {
+	switch exp.nodeType {
+	case CallNode:
+		return evalCall(ds, exp, scopes)
+	case BodyNode:
+		valP := eval(ds, exp.children, scopes-1)
+		if valP != nil {
+			val := *valP
+			if len(val) > 0 && (val[0].dataType == BreakVal || val[0].dataType == ReturnVal) {
+				return valP, true
+			}
+		}
+		return valP, false
+	}
+	return &[]dataType{evalNodeValue(ds, exp, scopes)}, false
+}
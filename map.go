@@ -0,0 +1,191 @@
+package blisp
+
+import (
+	"math"
+	"strings"
+)
+
+// key of a Map entry, numbers are keyed by value like eq compares them so 1,
+// (float 1) and 1.00d are the same key, but 1 and "1" are different keys
+type mapKey struct {
+	dataType DataType
+	value    any
+}
+
+type mapEntry struct {
+	key     dataType
+	value   dataType
+	removed bool
+}
+
+// value of a Map, entries are kept in the order they were first set in and
+// index holds the position of each key in entries
+type hashMap struct {
+	index   map[mapKey]int
+	entries []mapEntry
+	removed int
+}
+
+func NewMap() *hashMap {
+	return &hashMap{index: make(map[mapKey]int)}
+}
+
+// hash key for val, only values compared by value can be keys
+func GetMapKey(val dataType) mapKey {
+	switch val.dataType {
+	case String, Bool, Nil, Symbol:
+		return mapKey{dataType: val.dataType, value: val.value}
+	case Int, Float, Rational, Decimal:
+		if n, ok := val.value.(int); ok {
+			return mapKey{dataType: Int, value: n}
+		}
+		if f, ok := val.value.(float64); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+			return mapKey{dataType: Float, value: f}
+		}
+		// whole numbers of any type share the Int key, the rest are keyed by
+		// their exact value as a fraction
+		r := ratOf(val)
+		if r.IsInt() && r.Num().IsInt64() && int64(int(r.Num().Int64())) == r.Num().Int64() {
+			return mapKey{dataType: Int, value: int(r.Num().Int64())}
+		}
+		return mapKey{dataType: Rational, value: r.RatString()}
+	}
+	throwError(TypeError, "Unable to use type ", dataTypes[val.dataType], " as a Map key")
+	return mapKey{}
+}
+
+func (m *hashMap) Get(key dataType) (dataType, bool) {
+	if i, ok := m.index[GetMapKey(key)]; ok {
+		return m.entries[i].value, true
+	}
+	return dataType{dataType: Nil, value: nil}, false
+}
+
+func (m *hashMap) Set(key dataType, value dataType) {
+	k := GetMapKey(key)
+	if i, ok := m.index[k]; ok {
+		m.entries[i].value = value
+		return
+	}
+	m.index[k] = len(m.entries)
+	m.entries = append(m.entries, mapEntry{key: key, value: value})
+}
+
+func (m *hashMap) Delete(key dataType) bool {
+	k := GetMapKey(key)
+	i, ok := m.index[k]
+	if !ok {
+		return false
+	}
+	delete(m.index, k)
+	m.entries[i] = mapEntry{removed: true}
+	m.removed++
+	if m.removed > len(m.entries)/2 {
+		m.compact()
+	}
+	return true
+}
+
+func (m *hashMap) Len() int {
+	return len(m.index)
+}
+
+// drops removed entries, keeping the order of the rest
+func (m *hashMap) compact() {
+	entries := make([]mapEntry, 0, len(m.index))
+	for _, entry := range m.entries {
+		if !entry.removed {
+			m.index[GetMapKey(entry.key)] = len(entries)
+			entries = append(entries, entry)
+		}
+	}
+	m.entries = entries
+	m.removed = 0
+}
+
+// entries in order, a copy so the map can be changed while going through it
+func (m *hashMap) Entries() []mapEntry {
+	res := make([]mapEntry, 0, len(m.index))
+	for _, entry := range m.entries {
+		if !entry.removed {
+			res = append(res, entry)
+		}
+	}
+	return res
+}
+
+func MakeMap(ds *dataStore, params []dataType) dataType {
+	if len(params)%2 != 0 {
+		throwError(ArityError, "Error in \"hash-map\", expected pairs of keys and values found ", len(params), " params")
+	}
+	m := NewMap()
+	for i := 0; i < len(params); i += 2 {
+		m.Set(GetDsValue(ds, params[i]), GetDsValue(ds, params[i+1]))
+	}
+	return dataType{dataType: Map, value: m}
+}
+
+// the Map in val, name is the function used in errors
+func GetMap(ds *dataStore, name string, val dataType) *hashMap {
+	val = GetDsValue(ds, val)
+	if val.dataType != Map {
+		throwError(TypeError, "Error in \"", name, "\", expected \"Map\" found ", dataTypes[val.dataType])
+	}
+	return val.value.(*hashMap)
+}
+
+func CompareMaps(ds *dataStore, val1 dataType, val2 dataType) bool {
+	m1 := val1.value.(*hashMap)
+	m2 := val2.value.(*hashMap)
+	if m1.Len() != m2.Len() {
+		return false
+	}
+	for _, entry := range m1.Entries() {
+		other, ok := m2.Get(entry.key)
+		if !ok || other.dataType != entry.value.dataType || !Eq(ds, entry.value, other) {
+			return false
+		}
+	}
+	return true
+}
+
+// {key: value, ...} with the entries in order
+func GetMapStr(data dataType) string {
+	parts := []string{}
+	for _, entry := range data.value.(*hashMap).Entries() {
+		parts = append(parts, GetStrValue(entry.key)+": "+GetStrValue(entry.value))
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+func LoopMapIterator(ds *dataStore, scopes int, m *hashMap, keyName dataType, valueName *dataType, body dataType) *[]dataType {
+	if keyName.dataType != Ident {
+		throwError(TypeError, "Error in \"loop\" expected \"Ident\" found ", dataTypes[keyName.dataType])
+	}
+	if valueName != nil {
+		checkIterator(*valueName)
+	}
+	for _, entry := range m.Entries() {
+		newIteration(ds, scopes)
+		MakeVar(ds, scopes+1, keyName.value.(string), entry.key, false)
+		if valueName != nil {
+			MakeVars(ds, scopes+1, *valueName, entry.value, false)
+		}
+		valP := eval(ds, GetBody("loop", body), scopes)
+		if valP != nil {
+			val := *valP
+			if len(val) > 0 && (val[0].dataType == BreakVal || val[0].dataType == ReturnVal) {
+				if val[0].dataType == ReturnVal {
+					return &[]dataType{val[0]}
+				}
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// used when a Map is printed or turned into a String
+func (m *hashMap) String() string {
+	return GetMapStr(dataType{dataType: Map, value: m})
+}
@@ -0,0 +1,183 @@
+package blisp
+
+import "strings"
+
+// (match value arm...) where each arm is (pattern result) or
+// (pattern when guard result). The arms are tried in order and the value of
+// the result of the first arm whose pattern matches, and whose guard is true,
+// is returned. Patterns can be
+//
+//	1 "a" true nil 'sym  a literal, matching values of the same type
+//	_                    anything
+//	name                 anything, bound to name
+//	(Int n)              a value of the type, matched with the pattern after it
+//	Color/red            a variant with the tag
+//	(Shape/circle r)     a variant with the tag, its fields matched in order
+//	[a 1 & rest]         a List with an item for each pattern
+//	{a b}                a Struct with the attributes, or a Map with the keys
+func Match(ds *dataStore, scopes int, params []dataType) *[]dataType {
+	val := GetDsValue(ds, params[0])
+	for _, arm := range params[1:] {
+		nodes := GetBody("match", arm)
+		pattern, guard, result := matchArm(nodes)
+		// names bound by an arm that did not match are dropped with its scope
+		RemoveScopedVars(ds, scopes)
+		if !matchPattern(ds, scopes, pattern, val) {
+			continue
+		}
+		if guard != nil {
+			ok := evalNodeValue(ds, guard, scopes)
+			if ok.dataType != Bool {
+				throwErrorAt(TypeError, guard.pos, "Error in \"match\", expected \"Bool\" guard found ", dataTypes[ok.dataType])
+			}
+			if !ok.value.(bool) {
+				continue
+			}
+		}
+		switch result.nodeType {
+		case BodyNode:
+			return evalBlock(ds, result.children, scopes)
+		case CallNode:
+			return eval(ds, []*node{result}, scopes)
+		}
+		return &[]dataType{evalNodeValue(ds, result, scopes)}
+	}
+	if val.dataType == Variant {
+		missing := missingTags(ds, params[1:], val.value.(variant).tag.of)
+		throwError(MatchError, "No arm of \"match\" matches ", GetStrValue(val), ", arms are missing ", strings.Join(missing, " "))
+	}
+	throwError(MatchError, "No arm of \"match\" matches ", GetStrValue(val), " of type ", dataTypes[val.dataType])
+	return nil
+}
+
+// pattern, guard and result of an arm, guard is nil if the arm has none
+func matchArm(nodes []*node) (*node, *node, *node) {
+	if len(nodes) == 2 {
+		return nodes[0], nil, nodes[1]
+	}
+	if len(nodes) == 4 && nodes[1].nodeType == IdentNode && nodes[1].value.value == "when" {
+		return nodes[0], nodes[2], nodes[3]
+	}
+	pos := position{}
+	if len(nodes) > 0 {
+		pos = nodes[0].pos
+	}
+	throwErrorAt(SyntaxError, pos, "Error in \"match\", expected (pattern result) or (pattern when guard result)")
+	return nil, nil, nil
+}
+
+// true if val matches n, names in the pattern are bound in the scope at depth
+// scopes+1 as it is matched
+func matchPattern(ds *dataStore, scopes int, n *node, val dataType) bool {
+	switch n.nodeType {
+	case IdentNode:
+		name := n.value.value.(string)
+		if t := lookupTag(ds, name); t != nil {
+			return matchVariant(ds, scopes, t, nil, val)
+		}
+		if name != "_" {
+			MakeVar(ds, scopes+1, name, val, false)
+		}
+		return true
+	case LiteralNode:
+		if n.value.dataType == Pattern {
+			return matchKeys(ds, scopes, n.value.value.(*pattern), val)
+		}
+		return n.value.dataType == val.dataType && Eq(ds, n.value, val)
+	case QuoteNode:
+		quoted := nodeToData(n.children[0])
+		return quoted.dataType == val.dataType && Eq(ds, quoted, val)
+	case ListNode:
+		return matchList(ds, scopes, n.children, val)
+	case CallNode:
+		return matchType(ds, scopes, n, val)
+	}
+	throwErrorAt(SyntaxError, n.pos, "Invalid pattern in \"match\"")
+	return false
+}
+
+func matchList(ds *dataStore, scopes int, patterns []*node, val dataType) bool {
+	if val.dataType != List {
+		return false
+	}
+	items := val.value.([]dataType)
+	rest := ""
+	if l := len(patterns); l >= 2 && patterns[l-2].nodeType == IdentNode && patterns[l-2].value.value == "&" {
+		if patterns[l-1].nodeType != IdentNode {
+			throwErrorAt(SyntaxError, patterns[l-1].pos, "Expected a name after & in \"match\"")
+		}
+		rest = patterns[l-1].value.value.(string)
+		patterns = patterns[:l-2]
+		if len(items) < len(patterns) {
+			return false
+		}
+	} else if len(items) != len(patterns) {
+		return false
+	}
+	for i, p := range patterns {
+		if !matchPattern(ds, scopes, p, items[i]) {
+			return false
+		}
+	}
+	if rest != "" && rest != "_" {
+		left := append([]dataType{}, items[len(patterns):]...)
+		MakeVar(ds, scopes+1, rest, dataType{dataType: List, value: left}, false)
+	}
+	return true
+}
+
+// {a b} matches a Struct with every attribute or a Map with every key
+func matchKeys(ds *dataStore, scopes int, p *pattern, val dataType) bool {
+	switch val.dataType {
+	case Struct:
+		for _, item := range p.items {
+			if !hasAttr(val, item.value.(string)) {
+				return false
+			}
+		}
+	case Map:
+		for _, item := range p.items {
+			if _, ok := val.value.(*hashMap).Get(dataType{dataType: String, value: item.value}); !ok {
+				return false
+			}
+		}
+	default:
+		return false
+	}
+	p.bind(ds, val, func(name string, val dataType) {
+		MakeVar(ds, scopes+1, name, val, false)
+	})
+	return true
+}
+
+func hasAttr(val dataType, name string) bool {
+	for _, attr := range val.value.(structVal).attrs {
+		if attr.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// (Type) or (Type pattern) matches a value of the type named by Type, or an
+// instance of the defstruct named by Type
+func matchType(ds *dataStore, scopes int, n *node, val dataType) bool {
+	children := n.children
+	if len(children) > 0 && children[0].nodeType == IdentNode {
+		if t := lookupTag(ds, children[0].value.value.(string)); t != nil {
+			return matchVariant(ds, scopes, t, children[1:], val)
+		}
+	}
+	if len(children) > 0 && children[0].nodeType == IdentNode && len(children) <= 2 {
+		name := children[0].value.value.(string)
+		is, ok := isRecord(ds, name, val)
+		if t, found := typeByName(name); found {
+			is, ok = t == val.dataType, true
+		}
+		if ok {
+			return is && (len(children) == 1 || matchPattern(ds, scopes, children[1], val))
+		}
+	}
+	throwErrorAt(SyntaxError, n.pos, "Invalid pattern in \"match\", expected a type like (Int n)")
+	return false
+}
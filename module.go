@@ -0,0 +1,185 @@
+package blisp
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// file loaded with require, its top level names live in env instead of the
+// scope of the file that required it
+type module struct {
+	name string
+	path string
+	env  *env
+	// names given to export, nil when the module exports every top level name
+	exports []string
+}
+
+func (m *module) String() string {
+	return "<module " + m.name + ">"
+}
+
+// value of an exported name of the module
+func (m *module) lookup(name string) (dataType, bool) {
+	if m.exports != nil && !StrArrIncludes(m.exports, name) {
+		throwError(NameError, "\"", name, "\" is not exported by module ", m.name)
+	}
+	if v, ok := m.env.vars[name]; ok {
+		return v.data, true
+	}
+	if f, ok := m.env.funcs[name]; ok {
+		return dataType{dataType: Func, value: f}, true
+	}
+	return dataType{}, false
+}
+
+// names the module exports, in no particular order when it exports everything
+func (m *module) exported() []string {
+	if m.exports != nil {
+		return m.exports
+	}
+	res := []string{}
+	for name := range m.env.vars {
+		res = append(res, name)
+	}
+	for name := range m.env.funcs {
+		res = append(res, name)
+	}
+	return res
+}
+
+// value of a qualified name like q/name where q holds a module
+func lookupQualified(e *env, name string) (dataType, bool) {
+	i := strings.Index(name, "/")
+	if i <= 0 || i == len(name)-1 {
+		return dataType{}, false
+	}
+	v := e.lookupVar(name[:i])
+	if v == nil {
+		return dataType{}, false
+	}
+	switch v.data.dataType {
+	case Module:
+		return v.data.value.(*module).lookup(name[i+1:])
+	case Enum:
+		return v.data.value.(*union).lookup(name[i+1:])
+	}
+	return dataType{}, false
+}
+
+// path of the file for (require name), looked up relative to the file doing
+// the require and then to each directory in BLISP_PATH, the .blisp extension
+// is optional. Names starting with std/ are the bundled standard library
+func FindModule(ds *dataStore, name string) string {
+	if path, ok := findStdModule(name); ok {
+		return path
+	}
+	dirs := []string{""}
+	if !filepath.IsAbs(name) {
+		dirs = []string{filepath.Dir(ds.files[len(ds.files)-1])}
+		for _, dir := range filepath.SplitList(os.Getenv("BLISP_PATH")) {
+			if dir != "" {
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	for _, dir := range dirs {
+		for _, file := range []string{name, name + ".blisp"} {
+			path := filepath.Join(dir, file)
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				if abs, err := filepath.Abs(path); err == nil {
+					return abs
+				}
+				return path
+			}
+		}
+	}
+	throwError(IOError, "Unable to find module \"", name, "\", searched ", strings.Join(dirs, ", "))
+	return ""
+}
+
+// loads the module for (require name), each file is only evaluated once
+func RequireModule(ds *dataStore, name string) *module {
+	path := FindModule(ds, name)
+	if m, ok := ds.modules[path]; ok {
+		return m
+	}
+	for i, file := range ds.files {
+		if file == path {
+			chain := append(append([]string{}, ds.files[i:]...), path)
+			throwError(RuntimeError, "Circular require: ", strings.Join(chain, " -> "))
+		}
+	}
+	code := readModule(path)
+
+	m := &module{name: name, path: path, env: newEnv(nil)}
+	frames, files, loading, funcDepth := ds.frames, ds.files, ds.module, ds.funcDepth
+	defer func() {
+		ds.frames, ds.files, ds.module, ds.funcDepth = frames, files, loading, funcDepth
+	}()
+	ds.frames = []*env{m.env}
+	ds.files = append(ds.files, path)
+	ds.module = m
+	ds.funcDepth = 0
+	eval(ds, ParseTokens(Tokenize(path, string(code))), 0)
+	ds.modules[path] = m
+	return m
+}
+
+// source of the module at path, from the standard library or from disk
+func readModule(path string) []byte {
+	var code []byte
+	var err error
+	if isStdModule(path) {
+		code, err = stdlib.ReadFile(path)
+	} else {
+		code, err = os.ReadFile(path)
+	}
+	if err != nil {
+		throwError(IOError, err)
+	}
+	return code
+}
+
+func Require(ds *dataStore, scopes int, params []dataType) {
+	if len(params) != 1 && len(params) != 3 {
+		throwError(ArityError, "Invalid number of parameters to \"require\", expected 1 or 3 found ", len(params))
+	}
+	name := GetDsValue(ds, params[0])
+	if name.dataType != String {
+		throwError(TypeError, "Error in \"require\", expected \"String\" found ", dataTypes[name.dataType])
+	}
+	if len(params) == 3 && (params[1].dataType != Ident || params[1].value != "as" || params[2].dataType != Ident) {
+		throwError(SyntaxError, "Error in \"require\", expected (require \"file\" as name)")
+	}
+
+	m := RequireModule(ds, name.value.(string))
+	if len(params) == 3 {
+		MakeVar(ds, scopes, params[2].value.(string), dataType{dataType: Module, value: m}, false)
+		return
+	}
+	e := GetEnv(ds, scopes)
+	for _, export := range m.exported() {
+		if v, ok := m.env.vars[export]; ok {
+			e.defineVar(v)
+		} else if f, ok := m.env.funcs[export]; ok {
+			e.defineFunc(f)
+		}
+	}
+}
+
+func Export(ds *dataStore, params []dataType) {
+	if ds.module == nil {
+		throwError(RuntimeError, "Error in \"export\", only a required module can export names")
+	}
+	if ds.module.exports == nil {
+		ds.module.exports = []string{}
+	}
+	for _, v := range params {
+		if v.dataType != Ident {
+			throwError(TypeError, "Error in \"export\", expected \"Ident\" found ", dataTypes[v.dataType])
+		}
+		ds.module.exports = append(ds.module.exports, v.value.(string))
+	}
+}
@@ -0,0 +1,337 @@
+package blisp
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/valyala/fastjson/fastfloat"
+)
+
+// Int values are an int, or a *big.Int when they do not fit in one, so
+// arithmetic on Ints is exact and promotes to math/big on overflow.
+// Rationals and Decimals are exact too, arithmetic on two numbers gives the
+// type of the higher ranked one: Int < Decimal < Rational < Float
+
+func numberRank(t DataType) int {
+	switch t {
+	case Decimal:
+		return 1
+	case Rational:
+		return 2
+	case Float:
+		return 3
+	}
+	return 0
+}
+
+// Int value of b, an int if it fits in one
+func intValue(b *big.Int) dataType {
+	if b.IsInt64() && b.Int64() >= math.MinInt && b.Int64() <= math.MaxInt {
+		return dataType{dataType: Int, value: int(b.Int64())}
+	}
+	return dataType{dataType: Int, value: b}
+}
+
+// value of an Int given to name as a count or code, which has to fit in an
+// int
+func intOf(name string, val dataType) int {
+	n, ok := val.value.(int)
+	if !ok {
+		throwError(TypeError, "Error in \"", name, "\", ", GetStrValue(val), " does not fit in an int")
+	}
+	return n
+}
+
+// number for r, an Int if it is whole
+func ratValue(r *big.Rat) dataType {
+	if r.IsInt() {
+		return intValue(new(big.Int).Set(r.Num()))
+	}
+	return dataType{dataType: Rational, value: r}
+}
+
+func bigOf(val dataType) *big.Int {
+	if b, ok := val.value.(*big.Int); ok {
+		return b
+	}
+	return big.NewInt(int64(val.value.(int)))
+}
+
+// exact value of a number, Floats have to be finite
+func ratOf(val dataType) *big.Rat {
+	switch val.dataType {
+	case Rational:
+		return val.value.(*big.Rat)
+	case Decimal:
+		return val.value.(decimal).rat()
+	case Float:
+		return new(big.Rat).SetFloat64(val.value.(float64))
+	}
+	return new(big.Rat).SetInt(bigOf(val))
+}
+
+// value of an Int or Decimal as a decimal
+func decimalOf(val dataType) decimal {
+	if val.dataType == Decimal {
+		return val.value.(decimal)
+	}
+	return decimal{unscaled: bigOf(val)}
+}
+
+func floatOf(val dataType) float64 {
+	switch v := val.value.(type) {
+	case int:
+		return float64(v)
+	case float64:
+		return v
+	}
+	f, _ := ratOf(val).Float64()
+	return f
+}
+
+// number for the result f of float arithmetic, whole results are Ints if
+// they fit in an int, as larger ones were already rounded
+func floatValue(f float64) dataType {
+	if math.Floor(f) == f && f >= math.MinInt && f < math.MaxInt {
+		return dataType{dataType: Int, value: int(f)}
+	}
+	return dataType{dataType: Float, value: f}
+}
+
+// number written as s, integers are parsed exactly, n/d is a Rational and
+// a number ending in d like 12.34d is a Decimal
+func parseNumber(s string) (dataType, bool) {
+	if n, err := strconv.Atoi(s); err == nil {
+		return dataType{dataType: Int, value: n}, true
+	}
+	if b, ok := new(big.Int).SetString(s, 10); ok {
+		return intValue(b), true
+	}
+	if d, ok := parseDecimal(s); ok {
+		return dataType{dataType: Decimal, value: d}, true
+	}
+	if num, den, found := strings.Cut(s, "/"); found {
+		n, nok := new(big.Int).SetString(num, 10)
+		d, dok := new(big.Int).SetString(den, 10)
+		if !nok || !dok || d.Sign() <= 0 || strings.HasPrefix(den, "+") {
+			return dataType{}, false
+		}
+		return ratValue(new(big.Rat).SetFrac(n, d)), true
+	}
+	f, err := fastfloat.Parse(s)
+	if err != nil {
+		return dataType{}, false
+	}
+	return floatValue(f), true
+}
+
+func isNumber(val dataType) bool {
+	switch val.dataType {
+	case Int, Float, Rational, Decimal:
+		return true
+	}
+	return false
+}
+
+// higher ranked type of two numbers, op is shown if either is not one
+func numberType(op string, a dataType, b dataType) DataType {
+	for _, v := range []dataType{a, b} {
+		if !isNumber(v) {
+			throwError(TypeError, "Cannot ", op, " type ", dataTypes[v.dataType])
+		}
+	}
+	if numberRank(a.dataType) > numberRank(b.dataType) {
+		return a.dataType
+	}
+	return b.dataType
+}
+
+// a op b for op one of + - *, exact unless either is a Float
+func arith(op string, a dataType, b dataType) dataType {
+	switch numberType(op, a, b) {
+	case Float:
+		x, y := floatOf(a), floatOf(b)
+		switch op {
+		case "+":
+			return dataType{dataType: Float, value: x + y}
+		case "-":
+			return dataType{dataType: Float, value: x - y}
+		}
+		return dataType{dataType: Float, value: x * y}
+	case Rational:
+		res := new(big.Rat)
+		switch op {
+		case "+":
+			res.Add(ratOf(a), ratOf(b))
+		case "-":
+			res.Sub(ratOf(a), ratOf(b))
+		default:
+			res.Mul(ratOf(a), ratOf(b))
+		}
+		return ratValue(res)
+	case Decimal:
+		return dataType{dataType: Decimal, value: decimalOf(a).arith(op, decimalOf(b))}
+	}
+	x, xok := a.value.(int)
+	y, yok := b.value.(int)
+	if xok && yok {
+		if res, ok := intArith(op, x, y); ok {
+			return dataType{dataType: Int, value: res}
+		}
+	}
+	res := new(big.Int)
+	switch op {
+	case "+":
+		res.Add(bigOf(a), bigOf(b))
+	case "-":
+		res.Sub(bigOf(a), bigOf(b))
+	default:
+		res.Mul(bigOf(a), bigOf(b))
+	}
+	return intValue(res)
+}
+
+// x op y, ok is false if it overflows an int
+func intArith(op string, x int, y int) (int, bool) {
+	switch op {
+	case "+":
+		res := x + y
+		return res, (res > x) == (y > 0)
+	case "-":
+		res := x - y
+		return res, (res < x) == (y > 0)
+	}
+	if x == 0 || y == 0 {
+		return 0, true
+	}
+	res := x * y
+	return res, res/y == x && !(x == -1 && y == math.MinInt) && !(y == -1 && x == math.MinInt)
+}
+
+// a / b, an Int if both are Ints dividing exactly. Other Ints give a Float,
+// or a Rational in exact mode. Decimals are rounded half to even to the
+// larger scale of the two
+func quotient(exact bool, a dataType, b dataType) dataType {
+	t := numberType("/", a, b)
+	if t == Float || (t == Int && !exact && bigOf(b).Sign() == 0) {
+		return dataType{dataType: Float, value: floatOf(a) / floatOf(b)}
+	}
+	if ratOf(b).Sign() == 0 {
+		throwError(RuntimeError, "Error in \"/\", division by zero")
+	}
+	switch t {
+	case Rational:
+		return ratValue(new(big.Rat).Quo(ratOf(a), ratOf(b)))
+	case Decimal:
+		return dataType{dataType: Decimal, value: decimalOf(a).quo(decimalOf(b))}
+	}
+	q, r := new(big.Int).QuoRem(bigOf(a), bigOf(b), new(big.Int))
+	if r.Sign() == 0 {
+		return intValue(q)
+	}
+	res := new(big.Rat).SetFrac(bigOf(a), bigOf(b))
+	if exact {
+		return ratValue(res)
+	}
+	f, _ := res.Float64()
+	return dataType{dataType: Float, value: f}
+}
+
+// base ^ exp, exact if base is exact and exp is an Int, though Ints to a
+// negative power are Floats unless in exact mode
+func power(exact bool, base dataType, exp dataType) dataType {
+	numberType("^", base, exp)
+	if base.dataType == Float || exp.dataType != Int || (base.dataType == Int && !exact && bigOf(exp).Sign() < 0) {
+		return floatValue(math.Pow(floatOf(base), floatOf(exp)))
+	}
+	e := bigOf(exp)
+	if e.Sign() >= 0 {
+		switch base.dataType {
+		case Int:
+			return intValue(new(big.Int).Exp(bigOf(base), e, nil))
+		case Decimal:
+			d := base.value.(decimal)
+			if e.IsInt64() && e.Int64() <= math.MaxInt32 {
+				return dataType{dataType: Decimal, value: decimal{unscaled: new(big.Int).Exp(d.unscaled, e, nil), scale: d.scale * int(e.Int64())}}
+			}
+		}
+	}
+	r := ratOf(base)
+	if r.Sign() == 0 && e.Sign() < 0 {
+		throwError(RuntimeError, "Error in \"^\", division by zero")
+	}
+	abs := new(big.Int).Abs(e)
+	num := new(big.Int).Exp(r.Num(), abs, nil)
+	den := new(big.Int).Exp(r.Denom(), abs, nil)
+	if e.Sign() < 0 {
+		num, den = den, num
+	}
+	return ratValue(new(big.Rat).SetFrac(num, den))
+}
+
+// Float results of arithmetic are made Ints by floatValue
+func numberResult(val dataType) dataType {
+	if val.dataType == Float {
+		return floatValue(val.value.(float64))
+	}
+	return val
+}
+
+// -1, 0 or 1 as a is less than, equal to or greater than b, ok is false if
+// either is NaN
+func compareNumbers(a dataType, b dataType) (res int, ok bool) {
+	x, xok := a.value.(int)
+	y, yok := b.value.(int)
+	if xok && yok {
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	}
+	if a.dataType == Int && b.dataType == Int {
+		return bigOf(a).Cmp(bigOf(b)), true
+	}
+	for _, v := range []dataType{a, b} {
+		if v.dataType == Float && (math.IsNaN(v.value.(float64)) || math.IsInf(v.value.(float64), 0)) {
+			return compareFloats(floatOf(a), floatOf(b))
+		}
+	}
+	return ratOf(a).Cmp(ratOf(b)), true
+}
+
+func compareFloats(x float64, y float64) (int, bool) {
+	switch {
+	case x < y:
+		return -1, true
+	case x > y:
+		return 1, true
+	case x == y:
+		return 0, true
+	}
+	return 0, false
+}
+
+// largest Int not greater than val, or smallest not less than it if up is
+// true, val is not a Float
+func roundNumber(val dataType, up bool) dataType {
+	r := ratOf(val)
+	res := new(big.Int).Div(r.Num(), r.Denom())
+	if up && !r.IsInt() {
+		res.Add(res, big.NewInt(1))
+	}
+	return intValue(res)
+}
+
+// val rounded toward zero as an Int, Floats have to be finite
+func truncNumber(val dataType) dataType {
+	if f, ok := val.value.(float64); ok && f >= math.MinInt && f < math.MaxInt {
+		return dataType{dataType: Int, value: int(f)}
+	}
+	r := ratOf(val)
+	return intValue(new(big.Int).Quo(r.Num(), r.Denom()))
+}
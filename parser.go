@@ -0,0 +1,297 @@
+package blisp
+
+type NodeType int
+
+const (
+	CallNode NodeType = iota
+	LiteralNode
+	ListNode
+	IdentNode
+	BodyNode
+	QuoteNode
+	QuasiquoteNode
+)
+
+// node of the syntax tree built by ParseTokens
+// CallNode -> (name params...), children holds the name and params
+// LiteralNode -> value holds the Int, Float, String, Bool or Nil
+// ListNode -> [items...], children holds the items
+// IdentNode -> value holds the Ident
+// BodyNode -> (body exprs...), children holds the exprs, evaluated only when
+// the body is run
+// QuoteNode -> (quote x) or 'x, children holds x which evaluates to its code
+// QuasiquoteNode -> (quasiquote x) or `x, same as QuoteNode except the parts
+// in (unquote y) or (unquote-splicing y) are evaluated
+// tail is set on calls whose value is returned by the function they are in,
+// the last expr of a function body and the param of a return
+type node struct {
+	nodeType NodeType
+	value    dataType
+	children []*node
+	pos      position
+	tail     bool
+	// code a macro call expanded to, expanded the first time the call runs
+	expansion *node
+	// block starting at this node compiled for the vm, compiled the first
+	// time it runs so it goes away with the nodes, like ones built by eval
+	chunk *chunk
+	// children of a call as they were written, set when the parser rewrote
+	// them so quoting the call gives back the code the user wrote
+	source []*node
+	// set on braces {a b} in the place of a name, which bind attributes
+	// instead of making a struct of the variables they name
+	binds bool
+}
+
+// arguments of these functions are not evaluated before the call, they are
+// wrapped in a BodyNode so the function can evaluate them when it needs to
+var deferredParams = map[string][]int{
+	"while": {0},
+	"if":    {1, 2},
+}
+
+// tokenizes and parses code, returning syntax errors instead of raising them
+func parse(file string, code string) (res []*node, err error) {
+	defer catchError(&err)
+	return ParseTokens(Tokenize(file, code)), nil
+}
+
+func ParseTokens(tokens []token) []*node {
+	res := []*node{}
+	for i := 0; i < len(tokens); {
+		n, next := parseNode(tokens, i)
+		res = append(res, n)
+		i = next
+	}
+	return res
+}
+
+// parses the node starting at tokens[i], returns it with the index after it
+func parseNode(tokens []token, i int) (*node, int) {
+	t := tokens[i]
+	switch t.tokenType {
+	case OpenParen:
+		return parseCall(tokens, i)
+	case OpenBracket:
+		return parseList(tokens, i)
+	case OpenBrace:
+		return parseBraces(tokens, i)
+	case CloseParen, CloseBracket, CloseBrace:
+		throwErrorAt(SyntaxError, t.pos, "Unexpected \"", t.value, "\"")
+	case QuoteToken:
+		if i+1 >= len(tokens) {
+			throwErrorAt(SyntaxError, t.pos, "Expected a form after ", t.value)
+		}
+		quoted, next := parseNode(tokens, i+1)
+		name := &node{nodeType: IdentNode, value: dataType{dataType: Ident, value: t.value}, pos: t.pos}
+		return newCallNode([]*node{name, quoted}, t.pos), next
+	case Identifier:
+		return &node{nodeType: IdentNode, value: GetDataTypeFromToken(t), pos: t.pos}, i + 1
+	}
+	return &node{nodeType: LiteralNode, value: GetDataTypeFromToken(t), pos: t.pos}, i + 1
+}
+
+func parseChildren(tokens []token, i int, end TokenType) ([]*node, int) {
+	children := []*node{}
+	for i < len(tokens) && tokens[i].tokenType != end {
+		var child *node
+		child, i = parseNode(tokens, i)
+		children = append(children, child)
+	}
+	return children, i
+}
+
+func parseCall(tokens []token, start int) (*node, int) {
+	children, i := parseChildren(tokens, start+1, CloseParen)
+	if i >= len(tokens) {
+		throwErrorAt(SyntaxError, tokens[start].pos, "Unclosed \"(\"")
+	}
+	return newCallNode(children, tokens[start].pos), i + 1
+}
+
+// node for a call to children, forms handled by the parser like body and
+// quote get their own kind of node
+func newCallNode(children []*node, pos position) *node {
+	n := &node{nodeType: CallNode, children: children, pos: pos}
+	if len(children) > 0 && children[0].nodeType == IdentNode {
+		name := children[0].value.value.(string)
+		switch name {
+		case "body":
+			n.nodeType = BodyNode
+			n.children = children[1:]
+		case "quote", "quasiquote":
+			if len(children) != 2 {
+				throwErrorAt(SyntaxError, pos, "Expected 1 form to ", name, " found ", len(children)-1)
+			}
+			n.nodeType = QuoteNode
+			if name == "quasiquote" {
+				n.nodeType = QuasiquoteNode
+			}
+			n.children = children[1:]
+		}
+		if rewrittenCalls[name] {
+			// the rewrites make new nodes in a copy of children, the nodes
+			// as written stay in source
+			n.source = children
+			children = append([]*node{}, children...)
+			n.children = children
+		}
+		switch name {
+		case "func", "macro":
+			// (name default) after &optional is not a call, its default is
+			// evaluated when the function is called without it
+			for i := 2; i < len(children)-1; i++ {
+				children[i] = toPattern(asBody(children[i]))
+			}
+		case "var", "const":
+			if len(children) > 1 {
+				children[1] = toPattern(children[1])
+			}
+		case "cond":
+			// clauses are tests and exprs, not calls
+			for i := 1; i < len(children); i++ {
+				children[i] = asBody(children[i])
+			}
+		case "when", "unless":
+			// the exprs after the test run as one block
+			if len(children) > 2 {
+				block := &node{nodeType: BodyNode, children: children[2:], pos: children[2].pos}
+				children = append(children[:2:2], block)
+				n.children = children
+			}
+		case "enum":
+			// tags with fields are not calls, nor are fields with a type
+			for i := 2; i < len(children); i++ {
+				if tag := asBody(children[i]); tag != children[i] {
+					tag.children = append([]*node{}, tag.children...)
+					for j := 1; j < len(tag.children); j++ {
+						tag.children[j] = asBody(tag.children[j])
+					}
+					children[i] = tag
+				}
+			}
+		case "variant":
+			for i := 2; i < len(children); i++ {
+				children[i] = asBody(children[i])
+			}
+		case "defstruct", "protocol":
+			// fields with a type or default, methods and clauses are not
+			// calls
+			for i := 2; i < len(children); i++ {
+				children[i] = asBody(children[i])
+			}
+		case "match":
+			// arms are matched against the value, not called
+			for i := 2; i < len(children); i++ {
+				children[i] = asBody(children[i])
+			}
+		case "loop":
+			// the names given to each item, the first param is the items
+			for i := 2; i < len(children)-1; i++ {
+				children[i] = toPattern(children[i])
+			}
+		}
+		for _, index := range deferredParams[name] {
+			if index+1 < len(children) && children[index+1].nodeType != BodyNode {
+				param := children[index+1]
+				children[index+1] = &node{nodeType: BodyNode, children: []*node{param}, pos: param.pos}
+			}
+		}
+		markTailCalls(name, children)
+	}
+	return n
+}
+
+// forms the parser rewrites the children of
+var rewrittenCalls = map[string]bool{
+	"func": true, "macro": true, "var": true, "const": true, "cond": true,
+	"when": true, "unless": true, "enum": true, "variant": true,
+	"defstruct": true, "protocol": true, "match": true, "loop": true,
+	"while": true, "if": true,
+}
+
+// n as a BodyNode if it is a call, for parts of forms that are not calls
+func asBody(n *node) *node {
+	if n.nodeType != CallNode {
+		return n
+	}
+	return &node{nodeType: BodyNode, children: n.children, pos: n.pos}
+}
+
+func markTailCalls(name string, children []*node) {
+	if name == "func" && len(children) > 2 && children[len(children)-1].nodeType == BodyNode {
+		body := children[len(children)-1].children
+		if len(body) > 0 {
+			markTail(body[len(body)-1])
+		}
+	} else if name == "return" && len(children) == 2 {
+		markTail(children[1])
+	}
+}
+
+// marks n as being in tail position, the value of n is the value of the
+// last expr of whichever branch of an if, cond, when, unless or match runs,
+// so those exprs are in tail position too
+func markTail(n *node) {
+	switch n.nodeType {
+	case BodyNode:
+		if len(n.children) > 0 {
+			markTail(n.children[len(n.children)-1])
+		}
+		return
+	case CallNode:
+		n.tail = true
+	default:
+		return
+	}
+	if len(n.children) == 0 || n.children[0].nodeType != IdentNode {
+		return
+	}
+	// a call too short to have branches is left for its arity check
+	if len(n.children) < 2 {
+		return
+	}
+	switch n.children[0].value.value.(string) {
+	case "if", "when", "unless":
+		for _, branch := range n.children[2:] {
+			if branch.nodeType == BodyNode {
+				markTail(branch)
+			}
+		}
+	case "cond":
+		// the test of a clause without exprs is its value, but it is
+		// checked for being true first
+		for _, clause := range n.children[1:] {
+			if clause.nodeType == BodyNode && len(clause.children) > 1 {
+				markTail(clause)
+			}
+		}
+	case "match":
+		for _, arm := range n.children[2:] {
+			if arm.nodeType != BodyNode {
+				continue
+			}
+			if l := len(arm.children); l == 2 || (l == 4 && arm.children[1].nodeType == IdentNode && arm.children[1].value.value == "when") {
+				markTail(arm.children[l-1])
+			}
+		}
+	}
+}
+
+// {a b} is a pattern binding attributes by name
+func parseBraces(tokens []token, start int) (*node, int) {
+	children, i := parseChildren(tokens, start+1, CloseBrace)
+	if i >= len(tokens) {
+		throwErrorAt(SyntaxError, tokens[start].pos, "Unclosed \"{\"")
+	}
+	p := newPattern(children, true)
+	return &node{nodeType: LiteralNode, value: dataType{dataType: Pattern, value: p}, pos: tokens[start].pos}, i + 1
+}
+
+func parseList(tokens []token, start int) (*node, int) {
+	children, i := parseChildren(tokens, start+1, CloseBracket)
+	if i >= len(tokens) {
+		throwErrorAt(SyntaxError, tokens[start].pos, "Error, unable to find end of array.")
+	}
+	return &node{nodeType: ListNode, children: children, pos: tokens[start].pos}, i + 1
+}
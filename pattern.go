@@ -0,0 +1,183 @@
+package blisp
+
+import "strings"
+
+// destructuring pattern, [a b & rest] binds the items of a List by position
+// and {a b} binds the attributes of a Struct or the String keys of a Map by
+// name. Items of a list pattern can be patterns too, and _ skips an item
+type pattern struct {
+	isStruct bool
+	// Idents and nested Patterns
+	items []dataType
+	// name after &, bound to a List of the items left over
+	rest string
+}
+
+func (p *pattern) String() string {
+	parts := []string{}
+	for _, item := range p.items {
+		parts = append(parts, GetStrValue(item))
+	}
+	if p.rest != "" {
+		parts = append(parts, "&", p.rest)
+	}
+	if p.isStruct {
+		return "{" + strings.Join(parts, " ") + "}"
+	}
+	return "[" + strings.Join(parts, " ") + "]"
+}
+
+// pattern for the list [a b & rest] or braces {a b} at n, the parser makes
+// these where names are bound
+func toPattern(n *node) *node {
+	if isStructLiteral(n) {
+		binding := *n
+		binding.binds = true
+		return &binding
+	}
+	if n.nodeType != ListNode {
+		return n
+	}
+	return &node{nodeType: LiteralNode, value: dataType{dataType: Pattern, value: newPattern(n.children, false)}, pos: n.pos}
+}
+
+// braces {a b} used as a value, which make a struct with the values of the
+// variables they name
+func isStructLiteral(n *node) bool {
+	return n.nodeType == LiteralNode && n.value.dataType == Pattern && n.value.value.(*pattern).isStruct && !n.binds
+}
+
+// struct the braces n make, {a b} is the same as (struct a a b b)
+func structLiteral(ds *dataStore, n *node) dataType {
+	pairs := []dataType{}
+	for _, name := range n.value.value.(*pattern).items {
+		pairs = append(pairs, name, name)
+	}
+	return MakeStruct(ds, pairs...)
+}
+
+func newPattern(children []*node, isStruct bool) *pattern {
+	p := &pattern{isStruct: isStruct}
+	for i := 0; i < len(children); i++ {
+		child := children[i]
+		if child.nodeType == IdentNode && child.value.value == "&" {
+			if isStruct || i != len(children)-2 || children[i+1].nodeType != IdentNode {
+				throwErrorAt(SyntaxError, child.pos, "Expected one name after & at the end of a list pattern")
+			}
+			p.rest = children[i+1].value.value.(string)
+			break
+		}
+		if child.nodeType == IdentNode {
+			p.items = append(p.items, child.value)
+		} else if !isStruct && child.nodeType == ListNode {
+			p.items = append(p.items, toPattern(child).value)
+		} else if !isStruct && child.nodeType == LiteralNode && child.value.dataType == Pattern {
+			p.items = append(p.items, child.value)
+		} else {
+			throwErrorAt(SyntaxError, child.pos, "Expected a name in pattern found ", GetStrValue(child.value))
+		}
+	}
+	return p
+}
+
+// names the pattern binds, in order
+func (p *pattern) names() []string {
+	res := []string{}
+	for _, item := range p.items {
+		if item.dataType == Pattern {
+			res = append(res, item.value.(*pattern).names()...)
+		} else if item.value != "_" {
+			res = append(res, item.value.(string))
+		}
+	}
+	if p.rest != "" {
+		res = append(res, p.rest)
+	}
+	return res
+}
+
+// calls bind with each name in the pattern and the part of val it gets,
+// items missing from a List or Map are nil
+func (p *pattern) bind(ds *dataStore, val dataType, bind func(name string, val dataType)) {
+	val = GetDsValue(ds, val)
+	if p.isStruct {
+		p.bindStruct(ds, val, bind)
+		return
+	}
+	if val.dataType != List {
+		throwError(TypeError, "Error destructuring ", p, ", expected \"List\" found ", dataTypes[val.dataType])
+	}
+	items := val.value.([]dataType)
+	for i, item := range p.items {
+		v := dataType{dataType: Nil, value: nil}
+		if i < len(items) {
+			v = items[i]
+		}
+		bindItem(ds, item, v, bind)
+	}
+	if p.rest != "" {
+		rest := []dataType{}
+		if len(items) > len(p.items) {
+			rest = append(rest, items[len(p.items):]...)
+		}
+		bind(p.rest, dataType{dataType: List, value: rest})
+	}
+}
+
+func (p *pattern) bindStruct(ds *dataStore, val dataType, bind func(name string, val dataType)) {
+	switch val.dataType {
+	case Struct:
+		attrs := val.value.(structVal).attrs
+		for _, item := range p.items {
+			name := item.value.(string)
+			found := false
+			for _, attr := range attrs {
+				if attr.name == name {
+					bind(name, *attr.attr)
+					found = true
+					break
+				}
+			}
+			if !found {
+				throwError(NameError, "Error destructuring ", p, ", struct has no attribute \"", name, "\"")
+			}
+		}
+	case Map:
+		m := val.value.(*hashMap)
+		for _, item := range p.items {
+			v, _ := m.Get(dataType{dataType: String, value: item.value})
+			bind(item.value.(string), v)
+		}
+	default:
+		throwError(TypeError, "Error destructuring ", p, ", expected \"Struct\" or \"Map\" found ", dataTypes[val.dataType])
+	}
+}
+
+func bindItem(ds *dataStore, item dataType, val dataType, bind func(name string, val dataType)) {
+	if item.dataType == Pattern {
+		item.value.(*pattern).bind(ds, val, bind)
+	} else if item.value != "_" {
+		bind(item.value.(string), val)
+	}
+}
+
+// binds name, an Ident or Pattern, to val in the scope at depth scopes
+func MakeVars(ds *dataStore, scopes int, name dataType, val dataType, isConst bool) {
+	if name.dataType == Pattern {
+		name.value.(*pattern).bind(ds, val, func(name string, val dataType) {
+			MakeVar(ds, scopes, name, val, isConst)
+		})
+		return
+	}
+	if name.dataType != Ident {
+		throwError(TypeError, "Expected a variable name or pattern found ", dataTypes[name.dataType])
+	}
+	MakeVar(ds, scopes, name.value.(string), val, isConst)
+}
+
+// checks that the name a loop binds is an Ident or Pattern
+func checkIterator(name dataType) {
+	if name.dataType != Ident && name.dataType != Pattern {
+		throwError(TypeError, "Error in \"loop\" expected \"Ident\" found ", dataTypes[name.dataType])
+	}
+}
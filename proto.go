@@ -0,0 +1,80 @@
+package blisp
+
+// method being run by ., super looks for the method after holder in the
+// prototype chain of this
+type methodCall struct {
+	this   dataType
+	holder *structVal
+}
+
+// (extend proto name value...) is a struct like (struct name value...) that
+// looks up attributes it does not have in proto, so structs made by a factory
+// can share methods instead of each having a copy
+func Extend(ds *dataStore, params []dataType) dataType {
+	proto := GetDsValue(ds, params[0]).value.(structVal)
+	res := MakeStruct(ds, params[1:]...)
+	s := res.value.(structVal)
+	s.proto = &proto
+	res.value = s
+	return res
+}
+
+// prototype of a struct, nil if it has none
+func Proto(ds *dataStore, val dataType) dataType {
+	s := GetDsValue(ds, val).value.(structVal)
+	if s.proto == nil {
+		return dataType{dataType: Nil, value: nil}
+	}
+	return dataType{dataType: Struct, value: *s.proto}
+}
+
+// attribute name of s, or of the first struct in its prototype chain that has
+// it. Methods of a record count as attributes of its instances. holder is the
+// struct it was found on and site the name errors in a call to it are shown in
+func lookupAttr(s structVal, name string) (attr *dataType, holder *structVal, site string) {
+	for cur := &s; cur != nil; cur = cur.proto {
+		for _, a := range cur.attrs {
+			if a.name == name {
+				return a.attr, cur, "."
+			}
+		}
+		if m, ok := cur.rec.method(name); ok {
+			return &dataType{dataType: Func, value: m}, cur, cur.rec.name + "." + name
+		}
+	}
+	return nil, nil, ""
+}
+
+// calls the method name found on holder with this, args are already looked up
+func callMethod(ds *dataStore, scopes int, this dataType, start structVal, name string, args []dataType) *[]dataType {
+	fn, holder, site := lookupAttr(start, name)
+	if fn == nil {
+		if p := claimedBy(start, name); p != nil {
+			throwError(TypeError, "Error in \".\", struct implementing ", p.name, " has no method \"", name, "\"")
+		}
+		throwError(NameError, "Error in \".\", struct has no attribute \"", name, "\"")
+	}
+	if fn.dataType != Func {
+		throwError(TypeError, "Error in \".\", expected \"Func\" found ", dataTypes[fn.dataType])
+	}
+	ds.methods = append(ds.methods, methodCall{this: this, holder: holder})
+	defer func() { ds.methods = ds.methods[:len(ds.methods)-1] }()
+	return CallWithArgs(ds, scopes, site, fn.value.(function), append([]dataType{this}, args...))
+}
+
+// (super method args...) calls method on this, starting the lookup at the
+// prototype of the struct the running method was found on
+func Super(ds *dataStore, scopes int, params []dataType) *[]dataType {
+	if len(ds.methods) == 0 {
+		throwError(RuntimeError, "Error in \"super\", not in a method called with \".\"")
+	}
+	call := ds.methods[len(ds.methods)-1]
+	if call.holder.proto == nil {
+		throwError(NameError, "Error in \"super\", struct has no prototype")
+	}
+	args := []dataType{}
+	for _, v := range params[1:] {
+		args = append(args, GetDsValue(ds, v))
+	}
+	return callMethod(ds, scopes, call.this, *call.holder.proto, params[0].value.(string), args)
+}
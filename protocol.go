@@ -0,0 +1,108 @@
+package blisp
+
+import "fmt"
+
+// methods a struct needs to have, declared with protocol
+type protocol struct {
+	name    string
+	methods []protocolMethod
+}
+
+// method of a protocol, arity is the number of args it is called with
+// after this
+type protocolMethod struct {
+	name  string
+	arity int
+}
+
+func (p *protocol) String() string {
+	return "<protocol " + p.name + ">"
+}
+
+// (protocol name (method param...)...) declares a protocol needing a method
+// for each (method param...), taking as many args as there are params
+func DefProtocol(ds *dataStore, scopes int, params []dataType) {
+	name := params[0]
+	if name.dataType != Ident {
+		throwError(TypeError, "Error in \"protocol\", expected \"Ident\" name found ", dataTypes[name.dataType])
+	}
+	p := &protocol{name: name.value.(string)}
+	for _, v := range params[1:] {
+		switch v.dataType {
+		case Ident:
+			// a method without args can be given without parens
+			p.methods = append(p.methods, protocolMethod{name: v.value.(string)})
+		case Body:
+			nodes := v.value.([]*node)
+			for _, n := range nodes {
+				if n.nodeType != IdentNode {
+					throwErrorAt(SyntaxError, n.pos, "Error in \"protocol\", expected (method param...)")
+				}
+			}
+			if len(nodes) == 0 {
+				throwError(SyntaxError, "Error in \"protocol\", expected (method param...)")
+			}
+			p.methods = append(p.methods, protocolMethod{name: nodes[0].value.value.(string), arity: len(nodes) - 1})
+		default:
+			throwError(TypeError, "Error in \"protocol\", expected (method param...) found ", dataTypes[v.dataType])
+		}
+	}
+	MakeVar(ds, scopes, p.name, dataType{dataType: Protocol, value: p}, true)
+}
+
+// why s does not implement p, empty if it does
+func (p *protocol) missing(s structVal) string {
+	for _, m := range p.methods {
+		attr, _, _ := lookupAttr(s, m.name)
+		if attr == nil || attr.dataType != Func {
+			return fmt.Sprint("missing method \"", m.name, "\"")
+		}
+		f := attr.value.(function)
+		if f.native == nil && paramCountError(m.name, f, m.arity+1) != "" {
+			return fmt.Sprint("method \"", m.name, "\" takes ", paramCountStr(f), " with this, expected ", m.arity+1)
+		}
+	}
+	return ""
+}
+
+// true if val is a struct with every method of the protocol p
+func Implements(ds *dataStore, val dataType, p dataType) bool {
+	val = GetDsValue(ds, val)
+	return val.dataType == Struct && GetDsValue(ds, p).value.(*protocol).missing(val.value.(structVal)) == ""
+}
+
+// (implements struct protocol...) checks the struct has the methods of each
+// protocol and returns it claiming them, so calling a method of one of them
+// that it no longer has names the protocol
+func Implement(ds *dataStore, params []dataType) dataType {
+	val := GetDsValue(ds, params[0])
+	s := val.value.(structVal)
+	for _, v := range params[1:] {
+		p := GetDsValue(ds, v).value.(*protocol)
+		if msg := p.missing(s); msg != "" {
+			throwError(TypeError, "Error in \"implements\", struct does not implement ", p.name, ", ", msg)
+		}
+		s.protocols = append(s.protocols, p)
+	}
+	val.value = s
+	return val
+}
+
+// protocol claimed by s or a struct in its prototype chain with a method
+// called name, nil if there is none
+func claimedBy(s structVal, name string) *protocol {
+	for cur := &s; cur != nil; cur = cur.proto {
+		claims := append([]*protocol{}, cur.protocols...)
+		if cur.rec != nil {
+			claims = append(claims, cur.rec.protocols...)
+		}
+		for _, p := range claims {
+			for _, m := range p.methods {
+				if m.name == name {
+					return p
+				}
+			}
+		}
+	}
+	return nil
+}
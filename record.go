@@ -0,0 +1,142 @@
+package blisp
+
+// type declared with defstruct, its instances are Structs with a value for
+// each field and share its methods
+type record struct {
+	name   string
+	fields []param
+	// methods get the instance as this before their params
+	methods map[string]function
+	// protocols the methods implement
+	protocols []*protocol
+}
+
+// (defstruct name field... (func method param... (body ...))...) declares the
+// record name and a function of the same name making an instance from an arg
+// for each field. Fields are given like params, so they can have types and
+// defaults after &optional. Methods are called as (. instance method args...)
+// and (implements protocol...) checks they implement each protocol
+func DefStruct(ds *dataStore, scopes int, params []dataType) {
+	name := params[0]
+	if name.dataType != Ident {
+		throwError(TypeError, "Error in \"defstruct\", expected \"Ident\" name found ", dataTypes[name.dataType])
+	}
+	nameStr := name.value.(string)
+	if IsReserved(ds, nameStr) {
+		throwError(NameError, "Struct name \"", nameStr, "\" is reserved")
+	}
+
+	e := GetEnv(ds, scopes)
+	rec := &record{name: nameStr, methods: make(map[string]function)}
+	fields := []dataType{}
+	claims := []*node{}
+	for _, v := range params[1:] {
+		if nodes, ok := recordClause(v, "func"); ok {
+			m := makeMethod(ds, scopes, e, nodes)
+			rec.methods[m.name] = m
+			continue
+		}
+		if nodes, ok := recordClause(v, "implements"); ok {
+			claims = append(claims, nodes[1:]...)
+			continue
+		}
+		fields = append(fields, v)
+	}
+	for _, n := range claims {
+		p := GetDsValue(ds, evalValue(ds, n, scopes))
+		if p.dataType != Protocol {
+			throwErrorAt(TypeError, n.pos, "Error in \"defstruct\", expected \"Protocol\" found ", dataTypes[p.dataType])
+		}
+		if msg := p.value.(*protocol).missing(structVal{rec: rec}); msg != "" {
+			throwErrorAt(TypeError, n.pos, "Error in \"defstruct\", ", nameStr, " does not implement ", p.value.(*protocol).name, ", ", msg)
+		}
+		rec.protocols = append(rec.protocols, p.value.(*protocol))
+	}
+	var rest string
+	rec.fields, rest = GetParams("defstruct", fields)
+	if rest != "" {
+		throwError(SyntaxError, "Error in \"defstruct\", fields can not use &rest")
+	}
+	for _, field := range rec.fields {
+		if field.pattern != nil {
+			throwError(SyntaxError, "Error in \"defstruct\", expected a field name found ", field.name)
+		}
+	}
+	e.defineFunc(function{name: nameStr, params: rec.fields, rec: rec, env: e})
+	// fields and methods can have the record as their type, so their types
+	// are looked up once it is defined
+	resolveTypes(ds, "defstruct", rec.fields, nil)
+	for methodName, m := range rec.methods {
+		m.returns = resolveTypes(ds, "func", m.params, m.returns)
+		rec.methods[methodName] = m
+	}
+}
+
+// nodes of a clause given to defstruct starting with name, like a method as
+// (func name param... (body ...)) or (implements protocol...)
+func recordClause(v dataType, name string) ([]*node, bool) {
+	if v.dataType != Body {
+		return nil, false
+	}
+	nodes := v.value.([]*node)
+	if len(nodes) == 0 || nodes[0].nodeType != IdentNode || nodes[0].value.value != name {
+		return nil, false
+	}
+	return nodes, true
+}
+
+func makeMethod(ds *dataStore, scopes int, e *env, nodes []*node) function {
+	if len(nodes) < 3 || nodes[1].nodeType != IdentNode {
+		throwErrorAt(SyntaxError, nodes[0].pos, "Error in \"defstruct\", expected (func name param... (body ...))")
+	}
+	data := []dataType{}
+	for _, n := range nodes[2:] {
+		data = append(data, evalValue(ds, n, scopes))
+	}
+	paramData, returns := ReturnType("func", data[:len(data)-1])
+	params, rest := GetParams("func", paramData)
+	params = append([]param{{name: "this"}}, params...)
+	return function{name: nodes[1].value.value.(string), body: GetBody("func", data[len(data)-1]), params: params, rest: rest, returns: returns, env: e}
+}
+
+func (r *record) method(name string) (function, bool) {
+	if r == nil {
+		return function{}, false
+	}
+	m, ok := r.methods[name]
+	return m, ok
+}
+
+// instance made from the fields bound by its constructor in the current scope
+func (r *record) instance(ds *dataStore) dataType {
+	attrs := []structAttr{}
+	for _, field := range r.fields {
+		val := GetDsValue(ds, dataType{dataType: Ident, value: field.name})
+		attrs = append(attrs, structAttr{name: field.name, attr: &val})
+	}
+	return dataType{dataType: Struct, value: structVal{attrs: attrs, rec: r}}
+}
+
+// checks val can be set to the field name of an instance
+func (r *record) checkField(ds *dataStore, name string, val dataType) {
+	for _, field := range r.fields {
+		if field.name != name {
+			continue
+		}
+		if field.typ != nil && !field.typ.includes(val) {
+			throwError(TypeError, "Error in \"set\", expected field ", name, " of ", r.name, " to be ", field.typ, " found ", GetType(ds, val))
+		}
+		return
+	}
+	throwError(NameError, "Error in \"set\", ", r.name, " has no field \"", name, "\"")
+}
+
+// true if val is an instance of the record made by the function called name,
+// ok is false if name does not name a record
+func isRecord(ds *dataStore, name string, val dataType) (is bool, ok bool) {
+	f, found := LookupFunc(ds, name)
+	if !found || f.rec == nil {
+		return false, false
+	}
+	return val.dataType == Struct && val.value.(structVal).rec == f.rec, true
+}
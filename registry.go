@@ -0,0 +1,209 @@
+package blisp
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// a builtin function, its params are checked against min, max and types
+// before fn is called so fn can use them without checking again
+type builtin struct {
+	name string
+	// names of the params shown by help, like "list item..."
+	usage string
+	doc   string
+	// number of params allowed, max is -1 when there is no limit
+	min int
+	max int
+	// types each param must have once idents are resolved, a nil entry or a
+	// param past the end is not checked. Params like bodies are code instead
+	// of values so they are left nil. An entry holding Ident is checked
+	// against the param as written, for params that are names
+	types [][]DataType
+	// types of the params past the end of types, repeated, for builtins
+	// taking pairs like struct
+	rest [][]DataType
+	// the name can still be used for variables, for names that are common
+	// in user code like list
+	shadowable bool
+	fn         func(*dataStore, int, []dataType) *[]dataType
+}
+
+// names that are not builtins but still can not be used for variables,
+// functions or macros
+var keywords []string = []string{
+	"true",
+	"false",
+	"body",
+	"quote",
+	"quasiquote",
+	"unquote",
+	"unquote-splicing",
+	"&optional",
+	"&rest",
+}
+
+var (
+	numberTypes = []DataType{Int, Float, Rational, Decimal}
+	intTypes    = []DataType{Int}
+	stringTypes = []DataType{String}
+	listTypes   = []DataType{List}
+	mapTypes    = []DataType{Map}
+	// the name of a variable
+	nameTypes = []DataType{Ident}
+)
+
+// adds the builtins to the registry of ds, a later builtin with the same
+// name replaces the earlier one
+func RegisterBuiltins(ds *dataStore, builtins []builtin) {
+	for i := range builtins {
+		ds.builtins[builtins[i].name] = &builtins[i]
+	}
+}
+
+func IsBuiltin(ds *dataStore, name string) *builtin {
+	return ds.builtins[name]
+}
+
+// builtin run by a call to name, nil if there is none or if name is a
+// shadowable builtin the program has defined a function with
+func calledBuiltin(ds *dataStore, name string) *builtin {
+	b := IsBuiltin(ds, name)
+	if b != nil && b.shadowable {
+		if _, ok := LookupFunc(ds, name); ok {
+			return nil
+		}
+	}
+	return b
+}
+
+func IsReserved(ds *dataStore, name string) bool {
+	if b := IsBuiltin(ds, name); b != nil {
+		return !b.shadowable
+	}
+	return StrArrIncludes(keywords, name)
+}
+
+// names of every builtin, sorted
+func BuiltinNames(ds *dataStore) []string {
+	res := make([]string, 0, len(ds.builtins))
+	for name := range ds.builtins {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// "2", "2 or 3", "2 or more" or "0 to 3" for the number of params b takes
+func (b *builtin) arityStr() string {
+	switch {
+	case b.max < 0:
+		return strconv.Itoa(b.min) + " or more"
+	case b.min == b.max:
+		return strconv.Itoa(b.min)
+	case b.min+1 == b.max:
+		return strconv.Itoa(b.min) + " or " + strconv.Itoa(b.max)
+	}
+	return strconv.Itoa(b.min) + " to " + strconv.Itoa(b.max)
+}
+
+func typesStr(types []DataType) string {
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = "\"" + dataTypes[t] + "\""
+	}
+	return strings.Join(names, " or ")
+}
+
+// error message for calling b with n params, empty if n is allowed
+func (b *builtin) arityError(n int) string {
+	if n < b.min || (b.max >= 0 && n > b.max) {
+		return fmt.Sprint("Invalid number of parameters to \"", b.name, "\", expected ", b.arityStr(), " found ", n)
+	}
+	return ""
+}
+
+func (b *builtin) checkParams(ds *dataStore, params []dataType) {
+	if msg := b.arityError(len(params)); msg != "" {
+		throwError(ArityError, msg)
+	}
+	for i, param := range params {
+		types := b.paramTypes(i)
+		if types == nil {
+			continue
+		}
+		val := param
+		if !typeIncludes(types, Ident) {
+			val = GetDsValue(ds, param)
+		}
+		if !typeIncludes(types, val.dataType) {
+			throwError(TypeError, "Error in \"", b.name, "\", expected ", typesStr(types), " found ", dataTypes[val.dataType])
+		}
+	}
+}
+
+// types param i of b must have, nil if it is not checked
+func (b *builtin) paramTypes(i int) []DataType {
+	if i < len(b.types) {
+		return b.types[i]
+	}
+	if len(b.rest) == 0 {
+		return nil
+	}
+	return b.rest[(i-len(b.types))%len(b.rest)]
+}
+
+func typeIncludes(types []DataType, t DataType) bool {
+	for _, v := range types {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+// true for builtins that run a user function, a return in its body ends the
+// function instead of the block the builtin is called in
+func (b *builtin) callsFunc() bool {
+	return b.name == "." || b.name == "super"
+}
+
+// checks the params and calls the builtin
+func (b *builtin) call(ds *dataStore, scopes int, params []dataType) *[]dataType {
+	b.checkParams(ds, params)
+	return b.fn(ds, scopes, params)
+}
+
+// (name usage) followed by the doc of the builtin
+func (b *builtin) help() string {
+	res := "(" + b.name
+	if b.usage != "" {
+		res += " " + b.usage
+	}
+	res += ")"
+	if b.doc != "" {
+		res += "\n  " + b.doc
+	}
+	return res
+}
+
+func Help(ds *dataStore, params []dataType) dataType {
+	if len(params) == 0 {
+		return dataType{dataType: String, value: strings.Join(BuiltinNames(ds), " ")}
+	}
+	// (help print) names the builtin, a variable holding a name is looked up
+	name := params[0]
+	if name.dataType != Ident || IsBuiltin(ds, name.value.(string)) == nil {
+		name = GetDsValue(ds, name)
+	}
+	if name.dataType != Ident && name.dataType != String && name.dataType != Symbol {
+		throwError(TypeError, "Error in \"help\", expected a builtin name found ", dataTypes[name.dataType])
+	}
+	b := IsBuiltin(ds, name.value.(string))
+	if b == nil {
+		throwError(NameError, "No builtin named \"", name.value, "\"")
+	}
+	return dataType{dataType: String, value: b.help()}
+}
@@ -1,11 +1,11 @@
 #!/bin/bash
 args="${*:2}"
 if [[ "$1" == "dev" ]]; then
-	go run ./*.go $args
+	go run ./cmd/blisp $args
 elif [[ "$1" == "prod" ]]; then
 	./blisp $args
 elif [[ "$1" == "build" ]]; then
-	go build
+	go build -o blisp ./cmd/blisp
 else
 	echo "Command not recognized: \"$1\""
 fi
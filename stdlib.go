@@ -0,0 +1,30 @@
+package blisp
+
+import (
+	"embed"
+	"io/fs"
+	"strings"
+)
+
+// standard library modules, loaded with (require "std/name") without
+// needing the files on disk
+//
+//go:embed std/*.blisp
+var stdlib embed.FS
+
+// path of the std module for name, false if name is not one
+func findStdModule(name string) (string, bool) {
+	if !strings.HasPrefix(name, "std/") {
+		return "", false
+	}
+	for _, file := range []string{name, name + ".blisp"} {
+		if info, err := fs.Stat(stdlib, file); err == nil && !info.IsDir() {
+			return file, true
+		}
+	}
+	return "", false
+}
+
+func isStdModule(path string) bool {
+	return strings.HasPrefix(path, "std/")
+}
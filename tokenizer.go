@@ -1,10 +1,8 @@
-package main
+package blisp
 
 import (
-	"math"
+	"strconv"
 	"strings"
-
-	"github.com/valyala/fastjson/fastfloat"
 )
 
 type TokenType int
@@ -20,11 +18,44 @@ const (
 	IntToken
 	FloatToken
 	NilToken
+	QuoteToken // ' ` , or ,@ before a form, value is the name of the form it expands to
+	OpenBrace
+	CloseBrace
+	RationalToken // like 1/3
+	DecimalToken  // like 12.34d
 )
 
 type token struct {
 	tokenType TokenType
 	value     any
+	pos       position
+}
+
+// source file a token was read from, kept so errors can show the line
+type source struct {
+	name  string
+	lines []string
+}
+
+type position struct {
+	src  *source
+	line int
+	col  int
+}
+
+func (p position) String() string {
+	if p.src == nil {
+		return ""
+	}
+	return p.src.name + ":" + strconv.Itoa(p.line) + ":" + strconv.Itoa(p.col)
+}
+
+// the line of source code the position points to
+func (p position) sourceLine() string {
+	if p.src == nil || p.line < 1 || p.line > len(p.src.lines) {
+		return ""
+	}
+	return p.src.lines[p.line-1]
 }
 
 func GetToken(val string) token {
@@ -40,21 +71,16 @@ func GetToken(val string) token {
 			t.tokenType = OpenBracket
 		case ']':
 			t.tokenType = CloseBracket
+		case '{':
+			t.tokenType = OpenBrace
+		case '}':
+			t.tokenType = CloseBrace
 		default:
 			{
-				num, err := fastfloat.Parse(val)
-				if err == nil {
-					if math.Floor(num) == num {
-						t.tokenType = IntToken
-						t.value = int(num)
-					} else {
-						t.tokenType = FloatToken
-						t.value = num
-					}
-					return t
-				} else {
-					t.tokenType = Identifier
+				if num, ok := parseNumber(val); ok {
+					return numberToken(num)
 				}
+				t.tokenType = Identifier
 			}
 		}
 	} else {
@@ -70,18 +96,8 @@ func GetToken(val string) token {
 			t.tokenType = NilToken
 			t.value = nil
 		} else {
-			num, err := fastfloat.Parse(val)
-			if err == nil {
-				if math.Floor(num) == num {
-					t.tokenType = IntToken
-					t.value = int(num)
-				} else {
-					t.tokenType = FloatToken
-					t.value = num
-				}
-				return t
-			} else {
-				t.tokenType = Identifier
+			if num, ok := parseNumber(val); ok {
+				return numberToken(num)
 			}
 			t.tokenType = Identifier
 		}
@@ -90,6 +106,18 @@ func GetToken(val string) token {
 	return t
 }
 
+func numberToken(num dataType) token {
+	switch num.dataType {
+	case Float:
+		return token{tokenType: FloatToken, value: num.value}
+	case Rational:
+		return token{tokenType: RationalToken, value: num.value}
+	case Decimal:
+		return token{tokenType: DecimalToken, value: num.value}
+	}
+	return token{tokenType: IntToken, value: num.value}
+}
+
 func GetString(str string) (string, int) {
 	for i, v := range str {
 		if v == '"' && ((i > 0 && str[i-1] != '\\') || (i > 1 && str[i-1] == '\\' && str[i-2] == '\\')) {
@@ -106,57 +134,118 @@ func GetComment(str string) int {
 			return i
 		}
 	}
-	return 0
+	return len(str)
 }
 
-func Tokenize(code string) []token {
+func Tokenize(file string, code string) []token {
+	src := &source{name: file, lines: strings.Split(code, "\n")}
 	res := []token{}
 	temp := make([]rune, 0, len(code)/6)
+	line := 1
+	lineStart := 0
+	tempStart := 0
+	posAt := func(i int) position {
+		return position{src: src, line: line, col: i - lineStart + 1}
+	}
+	open := []token{}
 	for i := 0; i < len(code); i++ {
 		if code[i] == '#' {
 			i += GetComment(code[i:])
+			if i < len(code) && code[i] == '\n' {
+				line++
+				lineStart = i + 1
+			}
 		} else if code[i] == '"' {
 			var t token
 			t.tokenType = StringToken
+			t.pos = posAt(i)
 			str, index := GetString(code[i:])
+			if index == 0 {
+				throwErrorAt(SyntaxError, t.pos, "Unterminated string")
+			}
 			t.value = str
 			res = append(res, t)
+			for _, c := range str {
+				if c == '\n' {
+					line++
+				}
+			}
+			if lastLine := strings.LastIndex(str, "\n"); lastLine >= 0 {
+				lineStart = i + lastLine + 1
+			}
 			i += index - 1
-		} else if code[i] == ' ' || code[i] == '\n' || code[i] == '\t' {
+		} else if code[i] == ' ' || code[i] == '\n' || code[i] == '\t' || code[i] == '\r' {
 			if len(temp) > 0 {
 				t := GetToken(string(temp))
+				t.pos = posAt(tempStart)
 				res = append(res, t)
 			}
 			temp = []rune{}
+			if code[i] == '\n' {
+				line++
+				lineStart = i + 1
+			}
+		} else if (code[i] == '\'' || code[i] == '`' || code[i] == ',') && len(temp) == 0 {
+			t := token{tokenType: QuoteToken, pos: posAt(i)}
+			switch code[i] {
+			case '\'':
+				t.value = "quote"
+			case '`':
+				t.value = "quasiquote"
+			case ',':
+				t.value = "unquote"
+				if i+1 < len(code) && code[i+1] == '@' {
+					t.value = "unquote-splicing"
+					i++
+				}
+			}
+			res = append(res, t)
 		} else {
-			var t token
 			switch code[i] {
-			case '(':
-				t.tokenType = OpenParen
-			case ')':
-				t.tokenType = CloseParen
-			case '[':
-				t.tokenType = OpenBracket
-			case ']':
-				t.tokenType = CloseBracket
+			case '(', '[', '{':
+			case ')', ']', '}':
 			default:
 				{
+					if len(temp) == 0 {
+						tempStart = i
+					}
 					temp = append(temp, rune(code[i]))
 					continue
 				}
 			}
-			t.value = string(code[i])
 			if len(temp) > 0 {
 				t := GetToken(string(temp))
+				t.pos = posAt(tempStart)
 				res = append(res, t)
 			}
 			temp = []rune{}
-			res = append(res, GetToken(string(code[i])))
+			t := GetToken(string(code[i]))
+			t.pos = posAt(i)
+			res = append(res, t)
+			if t.tokenType == OpenParen || t.tokenType == OpenBracket || t.tokenType == OpenBrace {
+				open = append(open, t)
+			} else {
+				expected := OpenParen
+				if t.tokenType == CloseBracket {
+					expected = OpenBracket
+				} else if t.tokenType == CloseBrace {
+					expected = OpenBrace
+				}
+				if len(open) == 0 || open[len(open)-1].tokenType != expected {
+					throwErrorAt(SyntaxError, t.pos, "Unexpected \"", string(code[i]), "\"")
+				}
+				open = open[:len(open)-1]
+			}
 		}
 	}
 	if len(string(temp)) > 0 {
 		t := GetToken(string(temp))
+		t.pos = posAt(tempStart)
 		res = append(res, t)
 	}
+	if len(open) > 0 {
+		last := open[len(open)-1]
+		throwErrorAt(SyntaxError, last.pos, "Unclosed \"", last.value, "\"")
+	}
 	return res
 }
@@ -0,0 +1,231 @@
+package blisp
+
+import "strings"
+
+// type declared with enum or variant, its values are Variants holding one of
+// its tags. Tags are named as Type/tag
+type union struct {
+	name string
+	tags []*tag
+}
+
+// case of a union, a tag with fields is made by calling Type/tag with a value
+// for each field, a tag without fields is a value itself
+type tag struct {
+	name   string
+	of     *union
+	fields []param
+}
+
+// value of a Variant
+type variant struct {
+	tag    *tag
+	values []dataType
+}
+
+func (u *union) String() string {
+	return "<enum " + u.name + ">"
+}
+
+func (t *tag) String() string {
+	return t.of.name + "/" + t.name
+}
+
+func (v variant) String() string {
+	if len(v.tag.fields) == 0 {
+		return v.tag.String()
+	}
+	parts := []string{v.tag.String()}
+	for _, val := range v.values {
+		parts = append(parts, GetStrValue(val))
+	}
+	return "(" + strings.Join(parts, " ") + ")"
+}
+
+// value of Type/name, the constructor for a tag with fields
+func (u *union) lookup(name string) (dataType, bool) {
+	for _, t := range u.tags {
+		if t.name == name {
+			return t.value(), true
+		}
+	}
+	return dataType{}, false
+}
+
+func (t *tag) value() dataType {
+	if len(t.fields) == 0 {
+		return dataType{dataType: Variant, value: variant{tag: t}}
+	}
+	return dataType{dataType: Func, value: function{name: t.String(), params: t.fields, tag: t}}
+}
+
+// instance made from the fields bound by its constructor in the current scope
+func (t *tag) instance(ds *dataStore) dataType {
+	values := []dataType{}
+	for _, field := range t.fields {
+		values = append(values, GetDsValue(ds, dataType{dataType: Ident, value: field.name}))
+	}
+	return dataType{dataType: Variant, value: variant{tag: t, values: values}}
+}
+
+// (enum Type tag...) declares Type with the tags, a tag given as
+// (tag field...) carries a value for each field
+func DefEnum(ds *dataStore, scopes int, params []dataType) {
+	u := defineUnion(ds, scopes, "enum", params[0])
+	for _, v := range params[1:] {
+		switch v.dataType {
+		case Ident:
+			addTag(ds, u, "enum", v.value.(string), nil)
+		case Body:
+			nodes := v.value.([]*node)
+			if len(nodes) == 0 || nodes[0].nodeType != IdentNode {
+				throwError(SyntaxError, "Error in \"enum\", expected a tag or (tag field...)")
+			}
+			addTag(ds, u, "enum", nodes[0].value.value.(string), paramData(nodes[1:]))
+		default:
+			throwError(TypeError, "Error in \"enum\", expected \"Ident\" tag found ", dataTypes[v.dataType])
+		}
+	}
+}
+
+// (variant Type tag field...) adds a tag carrying the fields to Type,
+// declaring Type if it does not exist yet
+func DefVariant(ds *dataStore, scopes int, params []dataType) {
+	u := defineUnion(ds, scopes, "variant", params[0])
+	if params[1].dataType != Ident {
+		throwError(TypeError, "Error in \"variant\", expected \"Ident\" tag found ", dataTypes[params[1].dataType])
+	}
+	addTag(ds, u, "variant", params[1].value.(string), params[2:])
+}
+
+// union named by name, made in the scope at depth scopes if there is none.
+// Only variant adds tags to a union that already exists, an enum declares all
+// of its tags at once
+func defineUnion(ds *dataStore, scopes int, in string, name dataType) *union {
+	if name.dataType != Ident {
+		throwError(TypeError, "Error in \"", in, "\", expected \"Ident\" name found ", dataTypes[name.dataType])
+	}
+	nameStr := name.value.(string)
+	if v := CurrentEnv(ds).lookupVar(nameStr); v != nil && v.data.dataType == Enum {
+		if in == "enum" {
+			throwError(NameError, "Error in \"enum\", ", nameStr, " is already declared")
+		}
+		return v.data.value.(*union)
+	}
+	if IsReserved(ds, nameStr) {
+		throwError(NameError, "Enum name \"", nameStr, "\" is reserved")
+	}
+	u := &union{name: nameStr}
+	MakeVar(ds, scopes, nameStr, dataType{dataType: Enum, value: u}, true)
+	return u
+}
+
+func addTag(ds *dataStore, u *union, in string, name string, fields []dataType) {
+	if _, ok := u.lookup(name); ok {
+		throwError(NameError, "Error in \"", in, "\", ", u.name, " already has the tag ", name)
+	}
+	t := &tag{name: name, of: u}
+	var rest string
+	t.fields, rest = GetParams(in, fields)
+	resolveTypes(ds, in, t.fields, nil)
+	if rest != "" {
+		throwError(SyntaxError, "Error in \"", in, "\", fields can not use &rest")
+	}
+	for _, field := range t.fields {
+		if field.pattern != nil {
+			throwError(SyntaxError, "Error in \"", in, "\", expected a field name found ", field.name)
+		}
+	}
+	u.tags = append(u.tags, t)
+}
+
+func CompareVariants(ds *dataStore, val1 dataType, val2 dataType) bool {
+	v1 := val1.value.(variant)
+	v2 := val2.value.(variant)
+	if v1.tag != v2.tag {
+		return false
+	}
+	for i := range v1.values {
+		if v1.values[i].dataType != v2.values[i].dataType || !Eq(ds, v1.values[i], v2.values[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// value of the field named by index
+func variantField(val dataType, index dataType) dataType {
+	v := val.value.(variant)
+	if index.dataType == Ident {
+		for i, field := range v.tag.fields {
+			if field.name == index.value.(string) {
+				return v.values[i]
+			}
+		}
+	}
+	throwError(NameError, "Error in \"get\", ", v.tag, " has no field \"", index.value, "\"")
+	return dataType{}
+}
+
+// tag named like Type/tag in code, nil if name does not name one
+func lookupTag(ds *dataStore, name string) *tag {
+	v, ok := lookupQualified(CurrentEnv(ds), name)
+	if !ok {
+		return nil
+	}
+	switch v.dataType {
+	case Variant:
+		return v.value.(variant).tag
+	case Func:
+		return v.value.(function).tag
+	}
+	return nil
+}
+
+// (Type/tag pattern...) matches a variant with the tag and a value matching
+// each pattern
+func matchVariant(ds *dataStore, scopes int, t *tag, patterns []*node, val dataType) bool {
+	if val.dataType != Variant || val.value.(variant).tag != t {
+		return false
+	}
+	values := val.value.(variant).values
+	if len(patterns) > len(values) {
+		throwErrorAt(SyntaxError, patterns[0].pos, "Error in \"match\", ", t, " has ", len(values), " fields found ", len(patterns), " patterns")
+	}
+	for i, p := range patterns {
+		if !matchPattern(ds, scopes, p, values[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// tags of u that no arm of a match covers, arms with a guard cover nothing
+func missingTags(ds *dataStore, arms []dataType, u *union) []string {
+	covered := map[*tag]bool{}
+	for _, arm := range arms {
+		if arm.dataType != Body {
+			continue
+		}
+		nodes := arm.value.([]*node)
+		if len(nodes) != 2 {
+			continue
+		}
+		p := nodes[0]
+		if p.nodeType == CallNode && len(p.children) > 0 {
+			p = p.children[0]
+		}
+		if p.nodeType == IdentNode {
+			if t := lookupTag(ds, p.value.value.(string)); t != nil {
+				covered[t] = true
+			}
+		}
+	}
+	missing := []string{}
+	for _, t := range u.tags {
+		if !covered[t] {
+			missing = append(missing, t.String())
+		}
+	}
+	return missing
+}
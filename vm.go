@@ -0,0 +1,491 @@
+package blisp
+
+type opCode byte
+
+const (
+	opConst     opCode = iota // push consts[a]
+	opMark                    // remember where the params of a call or items of a list start
+	opList                    // replace the values since the last mark with a List
+	opCall                    // call the values since the last mark at scope depth a, b is the index in builtins or -1
+	opCond                    // take the value since the last mark as a Bool condition, jump to a if false
+	opJump                    // jump to a
+	opClear                   // reset result slot a to nil
+	opLoop                    // start of an inlined loop, a is the address of its opEndLoop
+	opEndLoop                 // end of an inlined loop at scope depth a
+	opIteration               // start an iteration of an inlined loop with a new scope below depth a
+	opEndIf                   // end of an inlined if at scope depth a
+	opTailCall                // same as opCall but a call to a function is left as a TailCall value
+	opNode                    // push the value of nodes[a] at scope depth b, for forms the vm does not compile
+	opMacro                   // if nodes[a] calls a macro evaluate its expansion at scope depth dest and jump to b
+	opBinary                  // call builtins[b] at scope depth a with the top two values, done inline for two ints
+	opUpdate                  // call builtins[b] at scope depth a on a variable with the values since the last mark, done inline for ints
+)
+
+// where the result of an opCall, opEndIf or opEndLoop goes, negative dest
+// pushes it on the stack, anything else stores it in that result slot
+const pushResult = -1
+
+type instr struct {
+	op   opCode
+	a    int
+	b    int
+	dest int
+	pos  position
+}
+
+// compiled block of blisp code, the result of the block is result slot 0
+type chunk struct {
+	code   []instr
+	consts []dataType
+	nodes  []*node
+	// builtins the calls were resolved to when compiling
+	builtins []*builtin
+	slots    int
+}
+
+type loopFrame struct {
+	stack int
+	marks int
+	exit  int
+	slot  int
+}
+
+func (c *chunk) emit(op opCode, a int, b int, dest int, pos position) int {
+	c.code = append(c.code, instr{op: op, a: a, b: b, dest: dest, pos: pos})
+	return len(c.code) - 1
+}
+
+func (c *chunk) emitConst(val dataType, pos position) {
+	c.consts = append(c.consts, val)
+	c.emit(opConst, len(c.consts)-1, 0, pushResult, pos)
+}
+
+func (c *chunk) addNode(n *node) int {
+	c.nodes = append(c.nodes, n)
+	return len(c.nodes) - 1
+}
+
+func (c *chunk) addBuiltin(b *builtin) int {
+	c.builtins = append(c.builtins, b)
+	return len(c.builtins) - 1
+}
+
+func (c *chunk) newSlot() int {
+	c.slots++
+	return c.slots - 1
+}
+
+// compiled chunk for a block, blocks are compiled the first time they run
+func GetChunk(ds *dataStore, code []*node) *chunk {
+	if code[0].chunk == nil {
+		code[0].chunk = CompileBlock(ds, code)
+	}
+	return code[0].chunk
+}
+
+func CompileBlock(ds *dataStore, code []*node) *chunk {
+	c := &chunk{}
+	slot := c.newSlot()
+	compileBlock(ds, c, code, 0, slot)
+	return c
+}
+
+// compiles a block whose calls run at scope depth+1, the value of the block
+// is left in slot
+func compileBlock(ds *dataStore, c *chunk, code []*node, depth int, slot int) {
+	for _, n := range code {
+		if n.nodeType == CallNode {
+			compileCall(ds, c, n, depth+1, slot)
+		} else if n.nodeType == BodyNode {
+			c.emit(opClear, slot, 0, 0, n.pos)
+			compileBlock(ds, c, n.children, depth, slot)
+		}
+	}
+}
+
+func compileCall(ds *dataStore, c *chunk, n *node, depth int, dest int) {
+	if len(n.children) > 0 && n.children[0].nodeType == IdentNode {
+		switch n.children[0].value.value.(string) {
+		case "if":
+			if compileIf(ds, c, n, depth, dest) {
+				return
+			}
+		case "while":
+			if compileWhile(ds, c, n, depth, dest) {
+				return
+			}
+		}
+	}
+
+	builtinIndex := -1
+	if len(n.children) > 0 && n.children[0].nodeType == IdentNode {
+		// functions can be defined with the name of a shadowable builtin
+		// while the program runs, so calls to one are looked up then
+		if b := IsBuiltin(ds, n.children[0].value.value.(string)); b != nil && !b.shadowable {
+			builtinIndex = c.addBuiltin(b)
+			if compileInline(ds, c, n, b, builtinIndex, depth, dest) {
+				return
+			}
+		}
+	}
+	// macros are defined while the program runs, so any call that is not to a
+	// builtin checks for one first
+	macroCheck := -1
+	if builtinIndex < 0 && len(n.children) > 0 && n.children[0].nodeType == IdentNode {
+		macroCheck = c.emit(opMacro, c.addNode(n), 0, depth, n.pos)
+	}
+	c.emit(opMark, 0, 0, 0, n.pos)
+	for _, child := range n.children {
+		compileParam(ds, c, child, depth)
+	}
+	if n.tail && builtinIndex < 0 {
+		c.emit(opTailCall, depth, builtinIndex, dest, n.pos)
+	} else {
+		c.emit(opCall, depth, builtinIndex, dest, n.pos)
+	}
+	if macroCheck >= 0 {
+		c.code[macroCheck].b = len(c.code)
+	}
+}
+
+// builtins run inline by opBinary when both params are ints
+var binaryBuiltins = map[string]bool{"+": true, "-": true, "*": true, "/": true, "%": true, "<": true, "<=": true, ">": true, ">=": true, "eq": true}
+
+// builtins run inline by opUpdate when the variable holds an int
+var updateBuiltins = map[string]bool{"++": true, "--": true, "+=": true, "-=": true}
+
+// compiles calls to the arithmetic builtins so the common case of ints skips
+// the general call path, false if n is not one of them
+func compileInline(ds *dataStore, c *chunk, n *node, b *builtin, builtinIndex int, depth int, dest int) bool {
+	params := n.children[1:]
+	if binaryBuiltins[b.name] && len(params) == 2 {
+		compileParam(ds, c, params[0], depth)
+		compileParam(ds, c, params[1], depth)
+		c.emit(opBinary, depth, builtinIndex, dest, n.pos)
+		return true
+	}
+	if updateBuiltins[b.name] && len(params) == b.min && params[0].nodeType == IdentNode {
+		c.emit(opMark, 0, 0, 0, n.pos)
+		for _, p := range params {
+			compileParam(ds, c, p, depth)
+		}
+		c.emit(opUpdate, depth, builtinIndex, dest, n.pos)
+		return true
+	}
+	return false
+}
+
+// pushes the value of a param to a call at scope depth
+func compileParam(ds *dataStore, c *chunk, n *node, depth int) {
+	switch n.nodeType {
+	case CallNode:
+		compileCall(ds, c, n, depth+1, pushResult)
+	case ListNode:
+		c.emit(opMark, 0, 0, 0, n.pos)
+		for _, child := range n.children {
+			compileParam(ds, c, child, depth)
+		}
+		c.emit(opList, 0, 0, 0, n.pos)
+	case BodyNode:
+		c.emitConst(dataType{dataType: Body, value: n.children}, n.pos)
+	case QuoteNode, QuasiquoteNode:
+		c.emit(opNode, c.addNode(n), depth, pushResult, n.pos)
+	case LiteralNode:
+		if isStructLiteral(n) {
+			c.emit(opNode, c.addNode(n), depth, pushResult, n.pos)
+		} else {
+			c.emitConst(n.value, n.pos)
+		}
+	default:
+		c.emitConst(n.value, n.pos)
+	}
+}
+
+// (if cond (body ...) (body ...)) becomes
+// cond, opCond else, then block, opJump end, else: else block, end: opEndIf
+// anything else is left as a call to the "if" builtin
+func compileIf(ds *dataStore, c *chunk, n *node, depth int, dest int) bool {
+	if len(n.children) != 3 && len(n.children) != 4 {
+		return false
+	}
+	for _, branch := range n.children[2:] {
+		if branch.nodeType != BodyNode {
+			return false
+		}
+		// a branch ending in a value instead of a call is left to the builtin
+		if l := len(branch.children); l > 0 && branch.children[l-1].nodeType != CallNode && branch.children[l-1].nodeType != BodyNode {
+			return false
+		}
+	}
+	slot := c.newSlot()
+	c.emit(opMark, 0, 0, 0, n.pos)
+	compileParam(ds, c, n.children[1], depth)
+	condJump := c.emit(opCond, 0, 0, 0, n.pos)
+	c.emit(opClear, slot, 0, 0, n.pos)
+	compileBlock(ds, c, n.children[2].children, depth, slot)
+	endJump := c.emit(opJump, 0, 0, 0, n.pos)
+	c.code[condJump].a = len(c.code)
+	c.emit(opClear, slot, 0, 0, n.pos)
+	if len(n.children) == 4 {
+		compileBlock(ds, c, n.children[3].children, depth, slot)
+	}
+	c.code[endJump].a = len(c.code)
+	c.emit(opEndIf, depth, slot, dest, n.pos)
+	return true
+}
+
+// (while cond (body ...)) becomes
+// opLoop, start: cond, opCond end, opIteration, body block, opJump start,
+// end: opEndLoop
+func compileWhile(ds *dataStore, c *chunk, n *node, depth int, dest int) bool {
+	if len(n.children) != 3 || n.children[1].nodeType != BodyNode || len(n.children[1].children) != 1 || n.children[2].nodeType != BodyNode {
+		return false
+	}
+	slot := c.newSlot()
+	loop := c.emit(opLoop, 0, slot, 0, n.pos)
+	c.emit(opClear, slot, 0, 0, n.pos)
+	start := len(c.code)
+	c.emit(opMark, 0, 0, 0, n.pos)
+	compileParam(ds, c, n.children[1].children[0], depth)
+	condJump := c.emit(opCond, 0, 1, 0, n.pos)
+	c.emit(opClear, slot, 0, 0, n.pos)
+	c.emit(opIteration, depth, 0, 0, n.pos)
+	compileBlock(ds, c, n.children[2].children, depth+1, slot)
+	c.emit(opJump, start, 0, 0, n.pos)
+	end := c.emit(opEndLoop, depth, slot, dest, n.pos)
+	c.code[condJump].a = end
+	c.code[loop].a = end
+	return true
+}
+
+// runs a compiled block, scopes is the scope depth the block runs at,
+// returns the same as eval
+func RunChunk(ds *dataStore, c *chunk, scopes int) *[]dataType {
+	stack := []dataType{}
+	marks := []int{}
+	loops := []loopFrame{}
+	slots := make([]*[]dataType, c.slots)
+
+	setResult := func(dest int, valP *[]dataType) {
+		if dest >= 0 {
+			slots[dest] = valP
+		} else if valP != nil {
+			stack = append(stack, *valP...)
+		}
+	}
+	setValue := func(dest int, val dataType) {
+		if dest >= 0 {
+			slots[dest] = &[]dataType{val}
+		} else {
+			stack = append(stack, val)
+		}
+	}
+	popMark := func() []dataType {
+		mark := marks[len(marks)-1]
+		marks = marks[:len(marks)-1]
+		vals := make([]dataType, len(stack)-mark)
+		copy(vals, stack[mark:])
+		stack = stack[:mark]
+		return vals
+	}
+
+	for pc := 0; pc < len(c.code); pc++ {
+		in := c.code[pc]
+		switch in.op {
+		case opConst:
+			stack = append(stack, c.consts[in.a])
+		case opNode:
+			stack = append(stack, evalValue(ds, c.nodes[in.a], scopes+in.b))
+		case opMark:
+			marks = append(marks, len(stack))
+		case opList:
+			stack = append(stack, dataType{dataType: List, value: popMark()})
+		case opCall, opTailCall, opMacro:
+			var fromCustom bool
+			var valP *[]dataType
+			dest := in.dest
+			if in.op == opMacro {
+				exp := GetMacroExpansion(ds, c.nodes[in.a], scopes+in.dest)
+				if exp == nil {
+					continue
+				}
+				valP, _ = evalExpansion(ds, exp, scopes+in.dest)
+				RemoveScopedVars(ds, scopes+in.dest)
+				dest = c.code[in.b-1].dest
+				pc = in.b - 1
+			} else {
+				info := popMark()
+				if in.op == opTailCall && ds.funcDepth > 0 {
+					if call, ok := GetTailCall(ds, info, in.pos); ok {
+						setResult(in.dest, &[]dataType{call})
+						continue
+					}
+				}
+				if in.b >= 0 {
+					fromCustom, valP = callBuiltin(ds, c.builtins[in.b], scopes+in.a, info, in.pos)
+				} else {
+					fromCustom, valP = EvalFunc(ds, scopes+in.a, info, in.pos)
+				}
+				RemoveScopedVars(ds, scopes+in.a)
+			}
+			if valP != nil {
+				val := *valP
+				if len(val) > 0 && (val[0].dataType == BreakVal || val[0].dataType == ReturnVal) {
+					if fromCustom {
+						if val[0].dataType == ReturnVal {
+							valP = &[]dataType{val[0].value.(dataType)}
+						} else {
+							valP = nil
+						}
+					} else if val[0].dataType == BreakVal && len(loops) > 0 {
+						loop := loops[len(loops)-1]
+						stack = stack[:loop.stack]
+						marks = marks[:loop.marks]
+						slots[loop.slot] = nil
+						pc = loop.exit - 1
+						continue
+					} else {
+						return valP
+					}
+				}
+			}
+			setResult(dest, valP)
+		case opBinary:
+			x := GetDsValue(ds, stack[len(stack)-2])
+			y := GetDsValue(ds, stack[len(stack)-1])
+			stack = stack[:len(stack)-2]
+			b := c.builtins[in.b]
+			ds.inFunc = true
+			if res, ok := binaryInts(b.name, x, y); ok {
+				setValue(in.dest, res)
+				continue
+			}
+			_, valP := callBuiltin(ds, b, scopes+in.a, []dataType{{}, x, y}, in.pos)
+			RemoveScopedVars(ds, scopes+in.a)
+			setResult(in.dest, valP)
+		case opUpdate:
+			info := stack[marks[len(marks)-1]:]
+			b := c.builtins[in.b]
+			ds.inFunc = true
+			if res, ok := updateInt(ds, b.name, info); ok {
+				stack = stack[:marks[len(marks)-1]]
+				marks = marks[:len(marks)-1]
+				setValue(in.dest, res)
+				continue
+			}
+			info = append([]dataType{{}}, popMark()...)
+			_, valP := callBuiltin(ds, b, scopes+in.a, info, in.pos)
+			RemoveScopedVars(ds, scopes+in.a)
+			setResult(in.dest, valP)
+		case opCond:
+			// the condition is only read, so it is not copied off the stack
+			mark := marks[len(marks)-1]
+			marks = marks[:len(marks)-1]
+			condition := dataType{dataType: Nil, value: nil}
+			if len(stack) > mark {
+				condition = GetDsValue(ds, stack[mark])
+			}
+			stack = stack[:mark]
+			if condition.dataType != Bool {
+				err := &BlispError{Kind: TypeError, Site: "if", Message: "Error in \"if\", expected type: \"Bool\" found " + dataTypes[condition.dataType]}
+				if in.b == 1 {
+					err = &BlispError{Kind: TypeError, Site: "while", Message: "Error in \"while\", expected \"Bool\" condition, found " + dataTypes[condition.dataType]}
+				}
+				err.setPos(in.pos)
+				panic(err)
+			}
+			if !condition.value.(bool) {
+				pc = in.a - 1
+			}
+		case opJump:
+			pc = in.a - 1
+		case opClear:
+			slots[in.a] = nil
+		case opLoop:
+			loops = append(loops, loopFrame{stack: len(stack), marks: len(marks), exit: in.a, slot: in.b})
+		case opIteration:
+			newIteration(ds, scopes+in.a)
+		case opEndLoop:
+			loops = loops[:len(loops)-1]
+			RemoveScopedVars(ds, scopes+in.a)
+			setResult(in.dest, slots[in.b])
+		case opEndIf:
+			RemoveScopedVars(ds, scopes+in.a)
+			setResult(in.dest, slots[in.b])
+		}
+	}
+	return slots[0]
+}
+
+// same as EvalFunc for a builtin found when compiling
+func callBuiltin(ds *dataStore, b *builtin, scopes int, info []dataType, pos position) (bool, *[]dataType) {
+	ds.inFunc = true
+	defer setErrorSite(b.name, pos)
+	return b.callsFunc(), b.call(ds, scopes, info[1:])
+}
+
+// result of the builtin name run on the ints x and y, ok is false if they
+// are not both ints or the result needs the builtin, like on overflow
+func binaryInts(name string, x dataType, y dataType) (dataType, bool) {
+	a, aok := x.value.(int)
+	b, bok := y.value.(int)
+	if !aok || !bok || x.dataType != Int || y.dataType != Int {
+		return dataType{}, false
+	}
+	switch name {
+	case "+", "-", "*":
+		if res, ok := intArith(name, a, b); ok {
+			return dataType{dataType: Int, value: res}, true
+		}
+	case "/":
+		if b != 0 && b != -1 && a%b == 0 {
+			return dataType{dataType: Int, value: a / b}, true
+		}
+	case "%":
+		if b != 0 {
+			return dataType{dataType: Int, value: a % b}, true
+		}
+	case "<":
+		return dataType{dataType: Bool, value: a < b}, true
+	case "<=":
+		return dataType{dataType: Bool, value: a <= b}, true
+	case ">":
+		return dataType{dataType: Bool, value: a > b}, true
+	case ">=":
+		return dataType{dataType: Bool, value: a >= b}, true
+	case "eq":
+		return dataType{dataType: Bool, value: a == b}, true
+	}
+	return dataType{}, false
+}
+
+// runs the builtin name on the variable named by info[0] holding an int,
+// ok is false if it does not or the result needs the builtin
+func updateInt(ds *dataStore, name string, info []dataType) (dataType, bool) {
+	v := CurrentEnv(ds).lookupVar(info[0].value.(string))
+	if v == nil || v.isConst || v.data.dataType != Int {
+		return dataType{}, false
+	}
+	a, ok := v.data.value.(int)
+	if !ok {
+		return dataType{}, false
+	}
+	amount := dataType{dataType: Int, value: 1}
+	if len(info) > 1 {
+		amount = GetDsValue(ds, info[1])
+	}
+	b, ok := amount.value.(int)
+	if !ok || amount.dataType != Int {
+		return dataType{}, false
+	}
+	op := "+"
+	if name == "--" || name == "-=" {
+		op = "-"
+	}
+	res, ok := intArith(op, a, b)
+	if !ok {
+		return dataType{}, false
+	}
+	v.data = dataType{dataType: Int, value: res}
+	return v.data, true
+}
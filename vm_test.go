@@ -0,0 +1,143 @@
+package blisp
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// output of running the file path with stdin reading input, and the error
+// that ended it if any
+func runFile(t testing.TB, path string, useVM bool, input string) (string, error) {
+	stdin, err := os.CreateTemp(t.TempDir(), "stdin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdin.WriteString(input)
+	stdin.Seek(0, 0)
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldIn, oldOut := os.Stdin, os.Stdout
+	os.Stdin, os.Stdout = stdin, w
+	out := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		out <- string(b)
+	}()
+
+	in := New()
+	in.UseVM(useVM)
+	_, err = in.EvalFile(path)
+
+	os.Stdin, os.Stdout = oldIn, oldOut
+	w.Close()
+	stdin.Close()
+	return <-out, err
+}
+
+var update = flag.Bool("update", false, "rewrite the .out files of the fixtures")
+
+// lines naming an error kind, like "RuntimeError in" or "TypeError:"
+var errorLine = regexp.MustCompile(`\b[A-Z][a-z]+Error\b`)
+
+// checks the output of running path in both evaluators, which must not
+// print an error. fails is true for fixtures that end in an error, which is
+// added to the output after the things they print
+func checkFixture(t *testing.T, path string, golden string, fails bool) {
+	var want string
+	if golden != "" && !*update {
+		b, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = string(b)
+	}
+	for _, useVM := range []bool{false, true} {
+		got, err := runFile(t, path, useVM, "15\n")
+		if errorLine.MatchString(got) {
+			t.Errorf("vm=%v printed an error:\n%s", useVM, got)
+		}
+		if fails != (err != nil) {
+			t.Errorf("vm=%v returned error %v", useVM, err)
+		}
+		if err != nil {
+			got += err.Error() + "\n"
+		}
+		if golden == "" {
+			if !useVM {
+				want = got
+			} else if got != want {
+				t.Errorf("vm output differs\ntree walker:\n%s\nvm:\n%s", want, got)
+			}
+			continue
+		}
+		if *update && !useVM {
+			if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			want = got
+		}
+		if got != want {
+			t.Errorf("vm=%v output differs from %s\nwant:\n%s\ngot:\n%s", useVM, golden, want, got)
+		}
+	}
+}
+
+// the sample programs have to run without errors and the vm has to print
+// exactly what the tree walker prints
+func TestSamples(t *testing.T) {
+	files, _ := filepath.Glob("blisp-src/*.blisp")
+	for _, path := range files {
+		path := path
+		t.Run(path, func(t *testing.T) {
+			if testing.Short() && filepath.Base(path) == "binary-tree.blisp" {
+				t.Skip("takes several seconds")
+			}
+			checkFixture(t, path, "", false)
+		})
+	}
+}
+
+// each fixture in testdata prints what its .out file holds in both
+// evaluators, the ones in testdata/errors end with the error in the .out
+func TestFixtures(t *testing.T) {
+	for _, dir := range []string{"testdata", "testdata/errors"} {
+		files, _ := filepath.Glob(filepath.Join(dir, "*.blisp"))
+		for _, path := range files {
+			path := path
+			t.Run(path, func(t *testing.T) {
+				checkFixture(t, path, strings.TrimSuffix(path, ".blisp")+".out", dir != "testdata")
+			})
+		}
+	}
+}
+
+func benchmarkFile(b *testing.B, path string, useVM bool) {
+	code, err := os.ReadFile(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	null, _ := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	defer null.Close()
+	oldOut := os.Stdout
+	os.Stdout = null
+	defer func() { os.Stdout = oldOut }()
+	for i := 0; i < b.N; i++ {
+		in := New()
+		in.UseVM(useVM)
+		if _, err := in.EvalString(string(code)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLoopTreeWalker(b *testing.B) { benchmarkFile(b, "testdata/loops.blisp", false) }
+func BenchmarkLoopVM(b *testing.B)         { benchmarkFile(b, "testdata/loops.blisp", true) }
+func BenchmarkFibTreeWalker(b *testing.B)  { benchmarkFile(b, "testdata/fib.blisp", false) }
+func BenchmarkFibVM(b *testing.B)          { benchmarkFile(b, "testdata/fib.blisp", true) }